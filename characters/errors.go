@@ -2,6 +2,7 @@ package characters
 
 import (
 	"github.com/slpeople/errors"
+	slapi "github.com/slpeople/salesloftapi"
 
 	"github.com/go-chi/render"
 )
@@ -9,7 +10,7 @@ import (
 func ErrCharacterFrequency(err error) render.Renderer {
 	return &errors.ErrResponse{
 		Err:            err,
-		HTTPStatusCode: 500,
+		HTTPStatusCode: slapi.HTTPStatusCode(err),
 		StatusText:     "Error while calculating character frequency",
 		ErrorText:      err.Error(),
 	}
@@ -8,6 +8,13 @@ import (
 	slapi "github.com/slpeople/salesloftapi"
 )
 
+type (
+	Handler struct {
+		source    slapi.PeopleSource
+		blackList map[string]bool
+	}
+)
+
 var (
 	blackList = map[string]bool{
 		".": true,
@@ -15,18 +22,39 @@ var (
 	}
 )
 
+// NewHandler creates a Handler that analyzes the people provided by source,
+// leaving out the characters in blackList. A nil blackList leaves out "." and
+// "@".
+func NewHandler(source slapi.PeopleSource, blackList map[string]bool) *Handler {
+	if blackList == nil {
+		blackList = DefaultBlackList()
+	}
+	return &Handler{source: source, blackList: blackList}
+}
+
+// DefaultBlackList returns the characters left out of email address
+// character frequencies by default.
+func DefaultBlackList() map[string]bool {
+	defaults := make(map[string]bool, len(blackList))
+	for ch := range blackList {
+		defaults[ch] = true
+	}
+	return defaults
+}
+
 /*** Level 2: Unique Character Frequencies ***/
-func EmailCharacterFrequenciesHandler(w http.ResponseWriter, r *http.Request) {
-	people, err := slapi.ListPeople()
+func (h *Handler) EmailCharacterFrequenciesHandler(w http.ResponseWriter, r *http.Request) {
+	people, age, err := h.source.Snapshot(r.Context())
 	if err != nil {
 		render.Render(w, r, ErrCharacterFrequency(err))
 		return
 	}
+	slapi.SetSnapshotAge(w, age)
 	emailAddresses := make([]string, len(*people))
 	for i := range *people {
 		emailAddresses[i] = (*people)[i].EmailAddress
 	}
-	charFrequencies := CharacterFrequencyCountOfStrings(emailAddresses, blackList)
+	charFrequencies := CharacterFrequencyCountOfStrings(emailAddresses, h.blackList)
 	if err := render.Render(w, r, NewSortedCharacterFrequenciesResponse(&charFrequencies)); err != nil {
 		render.Render(w, r, errors.ErrRender(err))
 		return
@@ -0,0 +1,50 @@
+package characters
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	slapi "github.com/slpeople/salesloftapi"
+)
+
+func TestEmailCharacterFrequenciesHandler(t *testing.T) {
+	source := slapi.PeopleSourceFunc(func(ctx context.Context) (*slapi.People, time.Duration, error) {
+		return &slapi.People{
+			{EmailAddress: "ab@b.c"},
+			{EmailAddress: "b@c.a"},
+		}, 42 * time.Second, nil
+	})
+	w := httptest.NewRecorder()
+	NewHandler(source, nil).EmailCharacterFrequenciesHandler(w, httptest.NewRequest("GET", "/people/emails/char-frequencies", nil))
+
+	if w.Code != http.StatusOK || w.Header().Get("Age") != "42" {
+		t.Fatalf("unexpected response: \n\tstatus: %d\n\tage: %q\n", w.Code, w.Header().Get("Age"))
+	}
+	var result struct {
+		Frequencies SortedCharFreqs `json:"frequencies"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("unable to decode the response: %v\n", err)
+	}
+	expected := SortedCharFreqs{{"b", 3}, {"a", 2}, {"c", 2}}
+	if !cmp.Equal(result.Frequencies[0], expected[0]) || len(result.Frequencies) != len(expected) {
+		t.Fatalf("unexpected frequencies: \n\tresult: %#v\n\texpect: %#v\n", result.Frequencies, expected)
+	}
+}
+
+func TestEmailCharacterFrequenciesHandlerSourceError(t *testing.T) {
+	source := slapi.PeopleSourceFunc(func(ctx context.Context) (*slapi.People, time.Duration, error) {
+		return nil, 0, &slapi.RetriesExhaustedError{Attempts: 3, Err: errors.New("unavailable")}
+	})
+	w := httptest.NewRecorder()
+	NewHandler(source, nil).EmailCharacterFrequenciesHandler(w, httptest.NewRequest("GET", "/people/emails/char-frequencies", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected a 503 when the source is unavailable, got %d\n", w.Code)
+	}
+}
@@ -0,0 +1,56 @@
+// Command slmock serves a fixture of people as the SalesLoft people API, for
+// running the service locally or in CI without network access. Point the
+// service at http://localhost:$port/v2/people.json to use it.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/slpeople/salesloftapi/slmock"
+)
+
+var (
+	fixture    = flag.String("fixture", "", "A JSON or JSONL file of people to serve.")
+	port       = flag.String("port", "3001", "The port for the stand-in API. The default value is 3001.")
+	token      = flag.String("token", "", "The API key clients must present as a bearer token. When empty any key is accepted.")
+	latency    = flag.Duration("latency", 0, "Latency added to every response.")
+	fault      = flag.String("fault", "none", "The fault to inject: none, 429, 500 or malformed.")
+	faultFirst = flag.Int("fault-first", 0, "Inject the fault into this many requests before applying -fault-rate.")
+	faultRate  = flag.Float64("fault-rate", 0, "The probability, between 0 and 1, that a request receives the fault.")
+	retryAfter = flag.Duration("retry-after", 0, "The Retry-After sent with rate limited responses.")
+	seed       = flag.Int64("seed", 1, "The seed for random fault injection.")
+)
+
+func main() {
+	flag.Parse()
+	if *fixture == "" {
+		fmt.Fprintf(os.Stderr, "A fixture file of people is required.")
+		os.Exit(1)
+	}
+	people, err := slmock.LoadFixture(*fixture)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to load the fixture %s: %v", *fixture, err)
+		os.Exit(1)
+	}
+	faultKind, err := slmock.ParseFaultKind(*fault)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v", err)
+		os.Exit(2)
+	}
+
+	server := slmock.NewServer(people, slmock.Options{
+		Token:      *token,
+		Latency:    *latency,
+		Fault:      faultKind,
+		FaultFirst: *faultFirst,
+		FaultRate:  *faultRate,
+		RetryAfter: *retryAfter,
+		Seed:       *seed,
+	})
+	log.Printf("Serving %d people at http://localhost:%s%s\n", len(people), *port, slmock.PeoplePath)
+	log.Fatal(http.ListenAndServe(":"+*port, server))
+}
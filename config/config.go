@@ -0,0 +1,394 @@
+// Package config assembles the service's configuration. Settings are merged
+// in order of increasing precedence from built-in defaults, an optional YAML
+// or JSON config file, SLPEOPLE_* environment variables, and command line
+// flags, and are then validated together so that every problem is reported
+// at startup.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var (
+	// stdin is where the API key is read from when the key file is "-".
+	stdin io.Reader = os.Stdin
+)
+
+const (
+	// EnvPrefix prefixes the environment variable for every setting.
+	EnvPrefix = "SLPEOPLE_"
+
+	peoplePath = "/v2/people.json"
+)
+
+type (
+	Config struct {
+		APIKey Secret `json:"api_key" yaml:"api_key"`
+		// APIKeyFile names a file holding the API key, such as a Docker or
+		// Kubernetes secret, or "-" to read the key from stdin.
+		APIKeyFile string           `json:"api_key_file" yaml:"api_key_file"`
+		Port       string           `json:"port" yaml:"port"`
+		Server     ServerConfig     `json:"server" yaml:"server"`
+		SalesLoft  SalesLoftConfig  `json:"salesloft" yaml:"salesloft"`
+		Cache      CacheConfig      `json:"cache" yaml:"cache"`
+		Duplicates DuplicatesConfig `json:"duplicates" yaml:"duplicates"`
+		Characters CharactersConfig `json:"characters" yaml:"characters"`
+
+		warnings []string
+	}
+	ServerConfig struct {
+		ReadTimeout  Duration `json:"read_timeout" yaml:"read_timeout"`
+		WriteTimeout Duration `json:"write_timeout" yaml:"write_timeout"`
+		IdleTimeout  Duration `json:"idle_timeout" yaml:"idle_timeout"`
+		// ShutdownTimeout bounds how long in-flight requests may take to
+		// finish once the service is asked to stop.
+		ShutdownTimeout Duration `json:"shutdown_timeout" yaml:"shutdown_timeout"`
+	}
+	SalesLoftConfig struct {
+		// BaseURL is the root of the SalesLoft API, without the /v2 path.
+		BaseURL     string      `json:"base_url" yaml:"base_url"`
+		Timeout     Duration    `json:"timeout" yaml:"timeout"`
+		PageSize    int         `json:"page_size" yaml:"page_size"`
+		Concurrency int         `json:"concurrency" yaml:"concurrency"`
+		Retry       RetryConfig `json:"retry" yaml:"retry"`
+	}
+	RetryConfig struct {
+		Attempts  int      `json:"attempts" yaml:"attempts"`
+		BaseDelay Duration `json:"base_delay" yaml:"base_delay"`
+		MaxDelay  Duration `json:"max_delay" yaml:"max_delay"`
+	}
+	CacheConfig struct {
+		TTL Duration `json:"ttl" yaml:"ttl"`
+	}
+	DuplicatesConfig struct {
+		DistanceThreshold int `json:"distance_threshold" yaml:"distance_threshold"`
+		LengthThreshold   int `json:"length_threshold" yaml:"length_threshold"`
+		// MinClusterSize is the fewest email addresses a reported cluster of
+		// possible duplicates holds.
+		MinClusterSize int `json:"min_cluster_size" yaml:"min_cluster_size"`
+		// Metric names the metric email addresses are compared with. Distance
+		// metrics use the DistanceThreshold and similarity metrics the
+		// SimilarityThreshold, where zero selects the metric's default.
+		Metric              string  `json:"metric" yaml:"metric"`
+		SimilarityThreshold float64 `json:"similarity_threshold" yaml:"similarity_threshold"`
+		// LocalThreshold and DomainThreshold, when set, are the thresholds the
+		// local parts and the domains of possible duplicates must each be
+		// within, in the units of the Metric.
+		LocalThreshold  *float64 `json:"local_threshold" yaml:"local_threshold"`
+		DomainThreshold *float64 `json:"domain_threshold" yaml:"domain_threshold"`
+		// DecisionsFile names the file review decisions about possible
+		// duplicates are kept in. Empty keeps them in memory only.
+		DecisionsFile string `json:"decisions_file" yaml:"decisions_file"`
+		// People configures matching whole people, rather than their email
+		// addresses alone.
+		People PeopleMatchingConfig `json:"people" yaml:"people"`
+	}
+	// PeopleMatchingConfig weighs the similarity of people's email addresses,
+	// names, the sound of their names and titles in the score at which they
+	// are possibly the same person.
+	PeopleMatchingConfig struct {
+		Threshold      float64 `json:"threshold" yaml:"threshold"`
+		EmailWeight    float64 `json:"email_weight" yaml:"email_weight"`
+		NameWeight     float64 `json:"name_weight" yaml:"name_weight"`
+		PhoneticWeight float64 `json:"phonetic_weight" yaml:"phonetic_weight"`
+		TitleWeight    float64 `json:"title_weight" yaml:"title_weight"`
+		// NicknamesFile names a file of first names and their nicknames that
+		// replaces the bundled dictionary.
+		NicknamesFile string `json:"nicknames_file" yaml:"nicknames_file"`
+	}
+	CharactersConfig struct {
+		BlackList StringList `json:"blacklist" yaml:"blacklist"`
+	}
+
+	// ValidationError lists every problem found with a Config.
+	ValidationError []string
+
+	// setting binds a flag and an environment variable to a Config field.
+	setting struct {
+		flag  string
+		env   string
+		value flag.Value
+		usage string
+	}
+)
+
+// Default returns the configuration used when nothing else is provided.
+func Default() *Config {
+	return &Config{
+		Port: "3000",
+		Server: ServerConfig{
+			ReadTimeout:     Duration(15 * time.Second),
+			WriteTimeout:    Duration(2 * time.Minute),
+			IdleTimeout:     Duration(time.Minute),
+			ShutdownTimeout: Duration(30 * time.Second),
+		},
+		SalesLoft: SalesLoftConfig{
+			BaseURL:     "https://api.salesloft.com",
+			Timeout:     Duration(30 * time.Second),
+			PageSize:    100,
+			Concurrency: 4,
+			Retry: RetryConfig{
+				Attempts:  5,
+				BaseDelay: Duration(500 * time.Millisecond),
+				MaxDelay:  Duration(30 * time.Second),
+			},
+		},
+		Cache: CacheConfig{
+			TTL: Duration(time.Minute),
+		},
+		Duplicates: DuplicatesConfig{
+			DistanceThreshold: 1,
+			LengthThreshold:   1,
+			MinClusterSize:    2,
+			Metric:            "levenshtein",
+			People: PeopleMatchingConfig{
+				Threshold:      0.8,
+				EmailWeight:    0.55,
+				NameWeight:     0.2,
+				PhoneticWeight: 0.15,
+				TitleWeight:    0.1,
+			},
+		},
+		Characters: CharactersConfig{
+			BlackList: StringList{".", "@"},
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the config file named by
+// the -config flag or SLPEOPLE_CONFIG, the environment as read by getenv, and
+// the command line arguments, and then validates it.
+func Load(name string, args []string, getenv func(string) string) (*Config, error) {
+	configFile := getenv(EnvPrefix + "CONFIG")
+	// The config file has to be read before the flags are applied, so find
+	// it with a first pass over the arguments.
+	scan := flag.NewFlagSet(name, flag.ContinueOnError)
+	scan.SetOutput(ioutil.Discard)
+	scan.StringVar(&configFile, "config", configFile, "")
+	for _, s := range Default().settings() {
+		scan.Var(s.value, s.flag, s.usage)
+	}
+	if err := scan.Parse(args); err != nil && err != flag.ErrHelp {
+		return nil, err
+	}
+
+	c := Default()
+	if configFile != "" {
+		if err := c.readFile(configFile); err != nil {
+			return nil, fmt.Errorf("unable to read config file %s: %v", configFile, err)
+		}
+	}
+	if err := c.applyEnv(getenv); err != nil {
+		return nil, err
+	}
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.String("config", configFile, "A YAML or JSON config file. Its settings are overridden by environment variables and flags. (env "+EnvPrefix+"CONFIG)")
+	for _, s := range c.settings() {
+		flags.Var(s.value, s.flag, s.usage+" (env "+EnvPrefix+s.env+")")
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "apikey" {
+			c.warnings = append(c.warnings, "The API key was given with -apikey, where other users can see it. Use "+EnvPrefix+"API_KEY or -apikey-file instead.")
+		}
+	})
+
+	if err := c.readAPIKeyFile(); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Warnings lists settings that are valid but ill-advised.
+func (c *Config) Warnings() []string {
+	return c.warnings
+}
+
+// readAPIKeyFile reads the API key from APIKeyFile, if one was given.
+func (c *Config) readAPIKeyFile() error {
+	if c.APIKeyFile == "" {
+		return nil
+	}
+	if c.APIKey != "" {
+		return ValidationError{"only one of the API key and the API key file may be set"}
+	}
+	var contents []byte
+	var err error
+	if c.APIKeyFile == "-" {
+		contents, err = ioutil.ReadAll(stdin)
+	} else {
+		contents, err = ioutil.ReadFile(c.APIKeyFile)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read the API key: %v", err)
+	}
+	c.APIKey = Secret(strings.TrimSpace(string(contents)))
+	if c.APIKey == "" {
+		return fmt.Errorf("the API key file %s is empty", c.APIKeyFile)
+	}
+	return nil
+}
+
+// PeopleURL is the SalesLoft API's people endpoint.
+func (c *Config) PeopleURL() string {
+	return strings.TrimRight(c.SalesLoft.BaseURL, "/") + peoplePath
+}
+
+// Validate reports every setting that is missing or out of range.
+func (c *Config) Validate() error {
+	var errs ValidationError
+	if c.APIKey == "" {
+		errs = append(errs, "An API Key is required. Please obtain a SalesLoft API key from your account or contact SalesLoft Support (support@salesloft.com) for assistance.")
+	}
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		errs = append(errs, fmt.Sprintf("port %q must be a number between 1 and 65535", c.Port))
+	}
+	if c.Server.ReadTimeout < 0 || c.Server.WriteTimeout < 0 || c.Server.IdleTimeout < 0 || c.Server.ShutdownTimeout < 0 {
+		errs = append(errs, "server timeouts must not be negative")
+	}
+	if u, err := url.Parse(c.SalesLoft.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Sprintf("salesloft base URL %q must be an absolute http or https URL", c.SalesLoft.BaseURL))
+	}
+	if c.SalesLoft.Timeout < 0 {
+		errs = append(errs, "salesloft timeout must not be negative")
+	}
+	if c.SalesLoft.PageSize < 1 || c.SalesLoft.PageSize > 100 {
+		errs = append(errs, fmt.Sprintf("salesloft page size %d must be between 1 and 100", c.SalesLoft.PageSize))
+	}
+	if c.SalesLoft.Concurrency < 1 {
+		errs = append(errs, "salesloft concurrency must be at least 1")
+	}
+	if c.SalesLoft.Retry.Attempts < 1 {
+		errs = append(errs, "salesloft retry attempts must be at least 1")
+	}
+	if c.SalesLoft.Retry.BaseDelay < 0 {
+		errs = append(errs, "salesloft retry base delay must not be negative")
+	}
+	if c.SalesLoft.Retry.MaxDelay < c.SalesLoft.Retry.BaseDelay {
+		errs = append(errs, "salesloft retry max delay must not be less than the base delay")
+	}
+	if c.Cache.TTL < 0 {
+		errs = append(errs, "cache TTL must not be negative")
+	}
+	if c.Duplicates.DistanceThreshold < 0 {
+		errs = append(errs, "duplicates distance threshold must not be negative")
+	}
+	if c.Duplicates.LengthThreshold < 0 {
+		errs = append(errs, "duplicates length threshold must not be negative")
+	}
+	if c.Duplicates.MinClusterSize < 2 {
+		errs = append(errs, "duplicates minimum cluster size must be at least 2")
+	}
+	if c.Duplicates.SimilarityThreshold < 0 || c.Duplicates.SimilarityThreshold > 1 {
+		errs = append(errs, "duplicates similarity threshold must be between 0 and 1")
+	}
+	if (c.Duplicates.LocalThreshold != nil && *c.Duplicates.LocalThreshold < 0) || (c.Duplicates.DomainThreshold != nil && *c.Duplicates.DomainThreshold < 0) {
+		errs = append(errs, "duplicates local and domain thresholds must not be negative")
+	}
+	if people := c.Duplicates.People; people.EmailWeight < 0 || people.NameWeight < 0 || people.PhoneticWeight < 0 || people.TitleWeight < 0 ||
+		people.EmailWeight+people.NameWeight+people.PhoneticWeight+people.TitleWeight == 0 {
+		errs = append(errs, "people matching weights must not be negative and at least one must be positive")
+	}
+	if c.Duplicates.People.Threshold <= 0 || c.Duplicates.People.Threshold > 1 {
+		errs = append(errs, "people matching threshold must be greater than 0 and at most 1")
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (e ValidationError) Error() string {
+	return "invalid configuration:\n\t" + strings.Join(e, "\n\t")
+}
+
+// BlackListSet returns the character blacklist as a set.
+func (c *CharactersConfig) BlackListSet() map[string]bool {
+	set := make(map[string]bool, len(c.BlackList))
+	for _, ch := range c.BlackList {
+		set[ch] = true
+	}
+	return set
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"apikey", "API_KEY", &c.APIKey, "SalesLoft API Key for communications with SalesLoft API (https://developers.salesloft.com/api.html). Prefer the environment variable or -apikey-file, as flags are visible to other users."},
+		{"apikey-file", "API_KEY_FILE", (*stringValue)(&c.APIKeyFile), "A file holding the SalesLoft API Key, or - to read it from stdin."},
+		{"port", "PORT", (*stringValue)(&c.Port), "The port for the service."},
+		{"read-timeout", "READ_TIMEOUT", &c.Server.ReadTimeout, "The time limit for reading a request to the service. Zero means no limit."},
+		{"write-timeout", "WRITE_TIMEOUT", &c.Server.WriteTimeout, "The time limit for handling a request and writing its response. Zero means no limit."},
+		{"idle-timeout", "IDLE_TIMEOUT", &c.Server.IdleTimeout, "How long an idle keep-alive connection is kept open."},
+		{"shutdown-timeout", "SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout, "How long in-flight requests may take to finish when the service is stopped."},
+		{"base-url", "BASE_URL", (*stringValue)(&c.SalesLoft.BaseURL), "The root URL of the SalesLoft API."},
+		{"timeout", "TIMEOUT", &c.SalesLoft.Timeout, "The time limit for each request to the SalesLoft API. Zero means no limit."},
+		{"page-size", "PAGE_SIZE", (*intValue)(&c.SalesLoft.PageSize), "The number of people requested per page from the SalesLoft API, at most 100."},
+		{"concurrency", "CONCURRENCY", (*intValue)(&c.SalesLoft.Concurrency), "The maximum number of pages fetched from the SalesLoft API in parallel."},
+		{"retry-attempts", "RETRY_ATTEMPTS", (*intValue)(&c.SalesLoft.Retry.Attempts), "The maximum number of attempts made for each request to the SalesLoft API."},
+		{"retry-base-delay", "RETRY_BASE_DELAY", &c.SalesLoft.Retry.BaseDelay, "The delay before the first retry of a failed SalesLoft API request; later retries back off exponentially."},
+		{"retry-max-delay", "RETRY_MAX_DELAY", &c.SalesLoft.Retry.MaxDelay, "The longest delay between retries of a failed SalesLoft API request."},
+		{"cache-ttl", "CACHE_TTL", &c.Cache.TTL, "How long a snapshot of people is served before it is refreshed in the background."},
+		{"distance-threshold", "DISTANCE_THRESHOLD", (*intValue)(&c.Duplicates.DistanceThreshold), "The largest edit distance at which two email addresses are possible duplicates."},
+		{"length-threshold", "LENGTH_THRESHOLD", (*intValue)(&c.Duplicates.LengthThreshold), "The largest difference in length at which two email addresses are compared."},
+		{"min-cluster-size", "MIN_CLUSTER_SIZE", (*intValue)(&c.Duplicates.MinClusterSize), "The fewest email addresses a reported cluster of possible duplicates holds."},
+		{"metric", "METRIC", (*stringValue)(&c.Duplicates.Metric), "The metric email addresses are compared with: levenshtein, damerau, hamming, keyboard, keyboard-azerty, keyboard-dvorak, jarowinkler or jaccard."},
+		{"similarity-threshold", "SIMILARITY_THRESHOLD", (*floatValue)(&c.Duplicates.SimilarityThreshold), "The lowest similarity at which two email addresses are possible duplicates under the jarowinkler and jaccard metrics. Zero selects the metric's default."},
+		{"local-threshold", "LOCAL_THRESHOLD", optionalFloatValue{&c.Duplicates.LocalThreshold}, "The threshold the local parts of two email addresses must be within to be possible duplicates, in the units of the metric. Unset leaves them unlimited."},
+		{"domain-threshold", "DOMAIN_THRESHOLD", optionalFloatValue{&c.Duplicates.DomainThreshold}, "The threshold the domains of two email addresses must be within to be possible duplicates, in the units of the metric. Unset leaves them unlimited."},
+		{"decisions-file", "DECISIONS_FILE", (*stringValue)(&c.Duplicates.DecisionsFile), "The file review decisions about possible duplicate email addresses are kept in. Empty keeps them in memory only."},
+		{"person-threshold", "PERSON_THRESHOLD", (*floatValue)(&c.Duplicates.People.Threshold), "The lowest match score, between 0 and 1, at which two people are possibly the same person."},
+		{"person-email-weight", "PERSON_EMAIL_WEIGHT", (*floatValue)(&c.Duplicates.People.EmailWeight), "The weight of email address similarity in the person match score."},
+		{"person-name-weight", "PERSON_NAME_WEIGHT", (*floatValue)(&c.Duplicates.People.NameWeight), "The weight of name similarity in the person match score."},
+		{"person-phonetic-weight", "PERSON_PHONETIC_WEIGHT", (*floatValue)(&c.Duplicates.People.PhoneticWeight), "The weight of how alike names sound in the person match score."},
+		{"person-title-weight", "PERSON_TITLE_WEIGHT", (*floatValue)(&c.Duplicates.People.TitleWeight), "The weight of title similarity in the person match score."},
+		{"nicknames-file", "NICKNAMES_FILE", (*stringValue)(&c.Duplicates.People.NicknamesFile), "A file of first names and their nicknames, one name to a line followed by its nicknames, separated by commas. It replaces the bundled nicknames."},
+		{"blacklist", "BLACKLIST", &c.Characters.BlackList, "A comma separated list of characters left out of character frequencies."},
+	}
+}
+
+func (c *Config) readFile(path string) error {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yaml.UnmarshalStrict(contents, c)
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(contents))
+		decoder.DisallowUnknownFields()
+		return decoder.Decode(c)
+	}
+	return fmt.Errorf("unsupported config file type %q: expected .yaml, .yml or .json", filepath.Ext(path))
+}
+
+func (c *Config) applyEnv(getenv func(string) string) error {
+	for _, s := range c.settings() {
+		name := EnvPrefix + s.env
+		if value := getenv(name); value != "" {
+			if err := s.value.Set(value); err != nil {
+				return fmt.Errorf("invalid value %q for %s: %v", value, name, err)
+			}
+		}
+	}
+	return nil
+}
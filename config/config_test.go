@@ -0,0 +1,210 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func env(vars map[string]string) func(string) string {
+	return func(name string) string {
+		return vars[name]
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	fromFile := Default()
+	fromFile.APIKey = "file-key"
+	fromFile.Port = "8080"
+	fromFile.SalesLoft.BaseURL = "http://localhost:3001"
+	fromFile.SalesLoft.Timeout = Duration(10 * time.Second)
+	fromFile.SalesLoft.PageSize = 50
+	fromFile.SalesLoft.Retry.Attempts = 3
+	fromFile.Cache.TTL = Duration(5 * time.Minute)
+	fromFile.Duplicates.DistanceThreshold = 2
+	fromFile.Duplicates.Metric = "jarowinkler"
+	fromFile.Duplicates.SimilarityThreshold = 0.9
+	domainThreshold := 0.95
+	fromFile.Duplicates.DomainThreshold = &domainThreshold
+	fromFile.Duplicates.People.Threshold = 0.9
+	fromFile.Duplicates.People.TitleWeight = 0
+	fromFile.Characters.BlackList = StringList{".", "@", "_"}
+
+	loadTestData := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		expected func() *Config
+	}{
+		{
+			name: "defaults and flags",
+			args: []string{"-apikey", "flag-key"},
+			expected: func() *Config {
+				c := Default()
+				c.APIKey = "flag-key"
+				return c
+			},
+		},
+		{
+			name:     "yaml file",
+			args:     []string{"-config", "testdata/config.yaml"},
+			expected: func() *Config { return fromFile },
+		},
+		{
+			name:     "json file from the environment",
+			env:      map[string]string{"SLPEOPLE_CONFIG": "testdata/config.json"},
+			expected: func() *Config { return fromFile },
+		},
+		{
+			name: "environment overrides file",
+			args: []string{"-config", "testdata/config.yaml"},
+			env:  map[string]string{"SLPEOPLE_API_KEY": "env-key", "SLPEOPLE_CACHE_TTL": "30s", "SLPEOPLE_BLACKLIST": "@"},
+			expected: func() *Config {
+				c := *fromFile
+				c.APIKey = "env-key"
+				c.Cache.TTL = Duration(30 * time.Second)
+				c.Characters.BlackList = StringList{"@"}
+				return &c
+			},
+		},
+		{
+			name: "flags override environment",
+			args: []string{"-config", "testdata/config.yaml", "-apikey", "flag-key", "-page-size", "20", "-metric", "damerau", "-local-threshold", "1"},
+			env:  map[string]string{"SLPEOPLE_API_KEY": "env-key", "SLPEOPLE_PAGE_SIZE": "10", "SLPEOPLE_SIMILARITY_THRESHOLD": "0.95"},
+			expected: func() *Config {
+				c := *fromFile
+				c.APIKey = "flag-key"
+				c.SalesLoft.PageSize = 20
+				c.Duplicates.Metric = "damerau"
+				c.Duplicates.SimilarityThreshold = 0.95
+				localThreshold := 1.0
+				c.Duplicates.LocalThreshold = &localThreshold
+				return &c
+			},
+		},
+	}
+	for _, td := range loadTestData {
+		result, err := Load("slpeople", td.args, env(td.env))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v\n", td.name, err)
+		}
+		result.warnings = nil
+		if expected := td.expected(); !cmp.Equal(result, expected, cmp.AllowUnexported(Config{})) {
+			t.Fatalf("%s: unexpected configuration: %s\n", td.name, cmp.Diff(expected, result, cmp.AllowUnexported(Config{})))
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	loadErrorTestData := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		contains []string
+	}{
+		{
+			name:     "missing api key",
+			contains: []string{"An API Key is required"},
+		},
+		{
+			name: "every invalid setting is reported",
+			args: []string{"-apikey", "key", "-port", "0", "-base-url", "api.salesloft.com", "-page-size", "500", "-retry-attempts", "0", "-distance-threshold", "-1", "-similarity-threshold", "2", "-domain-threshold", "-1", "-person-threshold", "0"},
+			contains: []string{
+				`port "0"`,
+				`base URL "api.salesloft.com"`,
+				"page size 500",
+				"retry attempts",
+				"distance threshold",
+				"similarity threshold",
+				"local and domain thresholds",
+				"people matching threshold",
+			},
+		},
+		{
+			name:     "bad environment value",
+			env:      map[string]string{"SLPEOPLE_CACHE_TTL": "soon"},
+			contains: []string{"SLPEOPLE_CACHE_TTL"},
+		},
+		{
+			name:     "unknown file setting",
+			args:     []string{"-config", "testdata/unknown.yaml"},
+			contains: []string{"base_uri"},
+		},
+		{
+			name:     "key and key file",
+			args:     []string{"-apikey-file", "testdata/apikey"},
+			env:      map[string]string{"SLPEOPLE_API_KEY": "env-key"},
+			contains: []string{"only one of the API key and the API key file"},
+		},
+		{
+			name:     "missing file",
+			args:     []string{"-config", "testdata/missing.yaml"},
+			contains: []string{"testdata/missing.yaml"},
+		},
+	}
+	for _, td := range loadErrorTestData {
+		_, err := Load("slpeople", td.args, env(td.env))
+		if err == nil {
+			t.Fatalf("%s: expected an error\n", td.name)
+		}
+		for _, s := range td.contains {
+			if !strings.Contains(err.Error(), s) {
+				t.Fatalf("%s: expected the error to mention %q: \n\t%v\n", td.name, s, err)
+			}
+		}
+	}
+}
+
+func TestLoadAPIKeySources(t *testing.T) {
+	stdin = strings.NewReader("stdin-secret-key-0123456789\n")
+	defer func() { stdin = os.Stdin }()
+
+	apiKeyTestData := []struct {
+		name     string
+		args     []string
+		env      map[string]string
+		expected Secret
+		warned   bool
+	}{
+		{name: "environment", env: map[string]string{"SLPEOPLE_API_KEY": "env-key"}, expected: "env-key"},
+		{name: "flag", args: []string{"-apikey", "flag-key"}, expected: "flag-key", warned: true},
+		{name: "file", args: []string{"-apikey-file", "testdata/apikey"}, expected: "file-secret-key-0123456789"},
+		{name: "file from the environment", env: map[string]string{"SLPEOPLE_API_KEY_FILE": "testdata/apikey"}, expected: "file-secret-key-0123456789"},
+		{name: "stdin", args: []string{"-apikey-file", "-"}, expected: "stdin-secret-key-0123456789"},
+	}
+	for _, td := range apiKeyTestData {
+		c, err := Load("slpeople", td.args, env(td.env))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v\n", td.name, err)
+		}
+		if c.APIKey != td.expected || (len(c.Warnings()) > 0) != td.warned {
+			t.Fatalf("%s: unexpected API key: \n\tresult: %q, warnings: %v\n\texpect: %q\n", td.name, string(c.APIKey), c.Warnings(), string(td.expected))
+		}
+	}
+}
+
+func TestSecretIsRedacted(t *testing.T) {
+	c := Default()
+	c.APIKey = "file-secret-key-0123456789"
+	for _, printed := range []string{
+		fmt.Sprintf("%v", c),
+		fmt.Sprintf("%+v", c),
+		fmt.Sprintf("%#v", c),
+		fmt.Sprint(c.APIKey),
+	} {
+		if strings.Contains(printed, "secret") || !strings.Contains(printed, "****6789") {
+			t.Fatalf("the API key was not redacted: %s\n", printed)
+		}
+	}
+	if b, _ := json.Marshal(c); strings.Contains(string(b), "secret") {
+		t.Fatalf("the API key was not redacted: %s\n", b)
+	}
+	if short := Secret("short"); short.String() != "****" {
+		t.Fatalf("short keys should be fully redacted: %s\n", short)
+	}
+}
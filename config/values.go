@@ -0,0 +1,149 @@
+package config
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// Duration is a time.Duration written as a string such as "30s" in config
+	// files, environment variables and flags.
+	Duration time.Duration
+	// Secret holds a credential. It prints in redacted form so that it does
+	// not end up in logs, flag usage or error messages by accident.
+	Secret string
+	// StringList is written as a list in config files and as a comma
+	// separated string in environment variables and flags.
+	StringList []string
+
+	stringValue string
+	intValue    int
+	floatValue  float64
+	// optionalFloatValue sets a float that is nil until it is given.
+	optionalFloatValue struct{ f **float64 }
+)
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *Duration) Set(s string) error {
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	return d.Set(s)
+}
+
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	return d.Set(s)
+}
+
+// String shows at most the last four characters of long secrets, which is
+// enough to tell keys apart without revealing them.
+func (s Secret) String() string {
+	switch {
+	case s == "":
+		return ""
+	case len(s) < 16:
+		return "****"
+	}
+	return "****" + string(s[len(s)-4:])
+}
+
+func (s Secret) GoString() string {
+	return `config.Secret("` + s.String() + `")`
+}
+
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+func (s *Secret) Set(value string) error {
+	*s = Secret(value)
+	return nil
+}
+
+func (l StringList) String() string {
+	return strings.Join(l, ",")
+}
+
+func (l *StringList) Set(s string) error {
+	*l = StringList{}
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+func (s stringValue) String() string {
+	return string(s)
+}
+
+func (s *stringValue) Set(value string) error {
+	*s = stringValue(value)
+	return nil
+}
+
+func (i intValue) String() string {
+	return strconv.Itoa(int(i))
+}
+
+func (i *intValue) Set(value string) error {
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	*i = intValue(parsed)
+	return nil
+}
+
+func (f floatValue) String() string {
+	return strconv.FormatFloat(float64(f), 'g', -1, 64)
+}
+
+func (f *floatValue) Set(value string) error {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return err
+	}
+	*f = floatValue(parsed)
+	return nil
+}
+
+func (o optionalFloatValue) String() string {
+	if o.f == nil || *o.f == nil {
+		return ""
+	}
+	return strconv.FormatFloat(**o.f, 'g', -1, 64)
+}
+
+func (o optionalFloatValue) Set(value string) error {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return err
+	}
+	*o.f = &parsed
+	return nil
+}
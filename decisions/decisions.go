@@ -0,0 +1,260 @@
+// Package decisions keeps the decisions reviewers make about clusters of
+// possible duplicate email addresses, so that addresses marked as not being
+// duplicates are not reported again.
+package decisions
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The decisions a reviewer may make about a cluster.
+const (
+	Confirmed    = "confirmed"
+	NotDuplicate = "not_duplicate"
+	Ignored      = "ignored"
+)
+
+type (
+	// Decision is a reviewer's decision about the cluster, or pair, of
+	// canonical email addresses with the id ClusterID.
+	Decision struct {
+		ClusterID      string    `json:"clusterId"`
+		Decision       string    `json:"decision"`
+		EmailAddresses []string  `json:"emailAddresses"`
+		DecidedAt      time.Time `json:"decidedAt"`
+	}
+	// Store holds the latest decision about each cluster. A Store opened from
+	// a file appends each decision to it, so that decisions outlive the
+	// service. A Store is safe for concurrent use.
+	Store struct {
+		mu   sync.RWMutex
+		file *os.File
+		// damaged is set once a failed write could not be cut off the file,
+		// after which no more decisions are appended to it.
+		damaged   error
+		decisions map[string]Decision
+		// notDuplicates counts, for each pair of addresses, the clusters
+		// holding both whose latest decision is NotDuplicate.
+		notDuplicates map[[2]string]int
+	}
+)
+
+// ClusterID returns the id of the cluster of canonical email addresses,
+// which depends only on the distinct addresses and not their order.
+func ClusterID(canonicalAddresses []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(distinct(canonicalAddresses), "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Validate returns an error unless decision is Confirmed, NotDuplicate or
+// Ignored.
+func Validate(decision string) error {
+	switch decision {
+	case Confirmed, NotDuplicate, Ignored:
+		return nil
+	}
+	return fmt.Errorf("unknown decision %q: expected %s, %s or %s", decision, Confirmed, NotDuplicate, Ignored)
+}
+
+// NewStore creates a Store that keeps decisions in memory only.
+func NewStore() *Store {
+	return &Store{decisions: map[string]Decision{}, notDuplicates: map[[2]string]int{}}
+}
+
+// Open creates a Store that keeps decisions in the file at path, one JSON
+// object to a line, creating the file if it does not exist. Decisions in the
+// file are replayed in order, so the last decision about a cluster holds.
+//
+// A write cut short leaves part of a decision on a last line without a
+// newline. That line is cut off, and a whole decision on it is given its
+// newline, so that the next decision recorded starts a line of its own.
+func Open(path string) (*Store, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s, err := replay(file, path)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	s.file = file
+	for _, d := range s.decisions {
+		s.countPairs(d, 1)
+	}
+	return s, nil
+}
+
+// replay reads the decisions in the file at path into a new Store, ending
+// the file's last line as Open describes.
+func replay(file *os.File, path string) (*Store, error) {
+	s := NewStore()
+	reader := bufio.NewReader(file)
+	// complete is the length of the file's complete lines.
+	var complete int64
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		last := err == io.EOF
+		if err != nil && !last {
+			return nil, fmt.Errorf("unable to read decisions from %s: %v", path, err)
+		}
+		if last && len(data) == 0 {
+			return s, nil
+		}
+		trimmed := bytes.TrimSpace(data)
+		if last && !json.Valid(trimmed) {
+			if err := file.Truncate(complete); err != nil {
+				return nil, fmt.Errorf("unable to cut off the incomplete last line of %s: %v", path, err)
+			}
+			return s, nil
+		}
+		if len(trimmed) > 0 {
+			var d Decision
+			if err := json.Unmarshal(trimmed, &d); err != nil {
+				return nil, fmt.Errorf("unable to read decisions from %s: line %d: %v", path, line, err)
+			}
+			if err := Validate(d.Decision); err != nil {
+				return nil, fmt.Errorf("unable to read decisions from %s: line %d: %v", path, line, err)
+			}
+			d.EmailAddresses = distinct(d.EmailAddresses)
+			s.decisions[d.ClusterID] = d
+		}
+		if last {
+			if _, err := file.Write([]byte("\n")); err != nil {
+				return nil, fmt.Errorf("unable to end the last line of %s: %v", path, err)
+			}
+			return s, nil
+		}
+		complete += int64(len(data))
+	}
+}
+
+// Close closes the Store's file, if it has one.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// Record records decision about the cluster of canonical email addresses,
+// replacing any earlier decision about it, and returns what was recorded. A
+// decision that cannot be written is not recorded, and what was written of it
+// is cut off the file again.
+func (s *Store) Record(decision string, canonicalAddresses []string) (Decision, error) {
+	if err := Validate(decision); err != nil {
+		return Decision{}, err
+	}
+	d := Decision{
+		ClusterID:      ClusterID(canonicalAddresses),
+		Decision:       decision,
+		EmailAddresses: distinct(canonicalAddresses),
+		DecidedAt:      time.Now().UTC(),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.file != nil {
+		if err := s.write(d); err != nil {
+			return Decision{}, fmt.Errorf("unable to record the decision: %v", err)
+		}
+	}
+	if previous, ok := s.decisions[d.ClusterID]; ok {
+		s.countPairs(previous, -1)
+	}
+	s.decisions[d.ClusterID] = d
+	s.countPairs(d, 1)
+	return d, nil
+}
+
+// write appends d to the Store's file. Should the write fail, the file is
+// cut back to its previous end, so that a torn line is not followed by the
+// next decision; if even that fails, the Store refuses further writes.
+func (s *Store) write(d Decision) error {
+	if s.damaged != nil {
+		return s.damaged
+	}
+	line, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	end, err := s.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err = s.file.Write(append(line, '\n')); err == nil {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		if truncateErr := s.file.Truncate(end); truncateErr != nil {
+			s.damaged = fmt.Errorf("the decisions file holds part of a failed write: %v", truncateErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// Lookup returns the latest decision about the cluster with the id, and
+// whether there is one.
+func (s *Store) Lookup(clusterID string) (Decision, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	d, ok := s.decisions[clusterID]
+	return d, ok
+}
+
+// NotDuplicate reports whether the canonical email addresses a and b are in
+// a cluster whose latest decision is NotDuplicate.
+func (s *Store) NotDuplicate(a, b string) bool {
+	if b < a {
+		a, b = b, a
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.notDuplicates[[2]string{a, b}] > 0
+}
+
+// countPairs adds delta to the count of each pair of d's addresses if d is a
+// NotDuplicate decision, so that replacing a decision only touches the pairs
+// of its own cluster.
+func (s *Store) countPairs(d Decision, delta int) {
+	if d.Decision != NotDuplicate {
+		return
+	}
+	// The addresses are sorted, so each pair is keyed in order.
+	for i, a := range d.EmailAddresses {
+		for _, b := range d.EmailAddresses[i+1:] {
+			pair := [2]string{a, b}
+			if s.notDuplicates[pair] += delta; s.notDuplicates[pair] <= 0 {
+				delete(s.notDuplicates, pair)
+			}
+		}
+	}
+}
+
+// distinct returns the distinct strings of strs in sorted order.
+func distinct(strs []string) []string {
+	sorted := append([]string(nil), strs...)
+	sort.Strings(sorted)
+	unique := sorted[:0]
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			unique = append(unique, s)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,194 @@
+package decisions
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestClusterID(t *testing.T) {
+	id := ClusterID([]string{"dan@test.com", "dann@test.com"})
+	clusterIDTestData := []struct {
+		addresses []string
+		same      bool
+	}{
+		{addresses: []string{"dann@test.com", "dan@test.com"}, same: true},
+		{addresses: []string{"dan@test.com", "dann@test.com", "dan@test.com"}, same: true},
+		{addresses: []string{"dan@test.com", "danny@test.com"}, same: false},
+		{addresses: []string{"dan@test.com", "dann@test.com", "danny@test.com"}, same: false},
+	}
+	for _, td := range clusterIDTestData {
+		if result := ClusterID(td.addresses); (result == id) != td.same {
+			t.Fatalf("unexpected id for %v: \n\tresult: %s\n\texpect the same as %s: %v\n", td.addresses, result, id, td.same)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := NewStore()
+	if _, err := s.Record("maybe", []string{"dan@test.com", "dann@test.com"}); err == nil {
+		t.Fatalf("expected an unknown decision to be refused\n")
+	}
+	d, err := s.Record(NotDuplicate, []string{"danny@test.com", "dan@test.com", "dann@test.com"})
+	if err != nil {
+		t.Fatalf("unable to record a decision: %v\n", err)
+	}
+	if result, ok := s.Lookup(d.ClusterID); !ok || result.Decision != NotDuplicate {
+		t.Fatalf("unexpected decision: \n\tresult: %+v %v\n\texpect: %s\n", result, ok, NotDuplicate)
+	}
+	// Every pair of the cluster's addresses is not a duplicate, whichever
+	// way round it is asked about.
+	for _, pair := range [][2]string{{"dan@test.com", "dann@test.com"}, {"danny@test.com", "dan@test.com"}, {"dann@test.com", "danny@test.com"}} {
+		if !s.NotDuplicate(pair[0], pair[1]) {
+			t.Fatalf("expected %s and %s not to be duplicates\n", pair[0], pair[1])
+		}
+	}
+	if s.NotDuplicate("dan@test.com", "bob@example.com") {
+		t.Fatalf("expected addresses without a decision to be possible duplicates\n")
+	}
+	// A later decision overturns an earlier one.
+	if _, err := s.Record(Confirmed, []string{"dan@test.com", "dann@test.com", "danny@test.com"}); err != nil {
+		t.Fatalf("unable to record a decision: %v\n", err)
+	}
+	if s.NotDuplicate("dan@test.com", "dann@test.com") {
+		t.Fatalf("expected a confirmed cluster's addresses to be possible duplicates\n")
+	}
+	// A pair stays not a duplicate while another cluster holding it says so.
+	s.Record(NotDuplicate, []string{"dan@test.com", "dann@test.com"})
+	s.Record(NotDuplicate, []string{"dan@test.com", "dann@test.com", "danny@test.com"})
+	s.Record(Ignored, []string{"dan@test.com", "dann@test.com", "danny@test.com"})
+	if !s.NotDuplicate("dan@test.com", "dann@test.com") || s.NotDuplicate("dan@test.com", "danny@test.com") {
+		t.Fatalf("expected only the pair decided on in both clusters to remain not duplicates\n")
+	}
+}
+
+// A decision that cannot be written is not recorded, and leaves the file as
+// it was, so that it can still be opened.
+func TestRecordWriteFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "decisions")
+	if err != nil {
+		t.Fatalf("unable to create a directory: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "decisions.jsonl")
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("unable to open a new store: %v\n", err)
+	}
+	if _, err := s.Record(NotDuplicate, []string{"dan@test.com", "dann@test.com"}); err != nil {
+		t.Fatalf("unable to record a decision: %v\n", err)
+	}
+	s.file.Close()
+	if s.file, err = os.Open(path); err != nil {
+		t.Fatalf("unable to reopen the file read-only: %v\n", err)
+	}
+	defer s.Close()
+	if _, err := s.Record(NotDuplicate, []string{"bob@example.com", "bobb@example.com"}); err == nil {
+		t.Fatalf("expected writing to a read-only file to fail\n")
+	}
+	if s.NotDuplicate("bob@example.com", "bobb@example.com") {
+		t.Fatalf("expected a decision that failed to be written not to be recorded\n")
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("unable to reopen the store: %v\n", err)
+	}
+	defer reopened.Close()
+	if !reopened.NotDuplicate("dan@test.com", "dann@test.com") || reopened.NotDuplicate("bob@example.com", "bobb@example.com") {
+		t.Fatalf("expected only the decision written to be replayed\n")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "decisions")
+	if err != nil {
+		t.Fatalf("unable to create a directory: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "decisions.jsonl")
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("unable to open a new store: %v\n", err)
+	}
+	if _, err := s.Record(NotDuplicate, []string{"dan@test.com", "dann@test.com"}); err != nil {
+		t.Fatalf("unable to record a decision: %v\n", err)
+	}
+	d, err := s.Record(Ignored, []string{"bob@example.com", "bobb@example.com"})
+	if err != nil {
+		t.Fatalf("unable to record a decision: %v\n", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("unable to close the store: %v\n", err)
+	}
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatalf("unable to reopen the store: %v\n", err)
+	}
+	defer s.Close()
+	if !s.NotDuplicate("dann@test.com", "dan@test.com") {
+		t.Fatalf("expected the recorded decision to be replayed\n")
+	}
+	if result, ok := s.Lookup(d.ClusterID); !ok || result.Decision != Ignored || !result.DecidedAt.Equal(d.DecidedAt) {
+		t.Fatalf("unexpected replayed decision: \n\tresult: %+v %v\n\texpect: %+v\n", result, ok, d)
+	}
+}
+
+// A last line without a newline is cut off if it is part of a decision, and
+// ended if it is a whole one, so that the next decision is on its own line.
+func TestOpenUnterminated(t *testing.T) {
+	dir, err := ioutil.TempDir("", "decisions")
+	if err != nil {
+		t.Fatalf("unable to create a directory: %v\n", err)
+	}
+	defer os.RemoveAll(dir)
+	whole := `{"clusterId":"338d694a401c0450","decision":"not_duplicate","emailAddresses":["dan@test.com","dann@test.com"]}`
+	unterminatedTestData := []struct {
+		name     string
+		contents string
+		replayed int
+	}{
+		{name: "partial", contents: whole + "\n" + `{"clusterId":"a9de`, replayed: 1},
+		{name: "whole", contents: whole, replayed: 1},
+		{name: "blank", contents: whole + "\n  ", replayed: 1},
+	}
+	for _, td := range unterminatedTestData {
+		path := filepath.Join(dir, td.name+".jsonl")
+		if err := ioutil.WriteFile(path, []byte(td.contents), 0644); err != nil {
+			t.Fatalf("unable to write %s: %v\n", path, err)
+		}
+		s, err := Open(path)
+		if err != nil {
+			t.Fatalf("%s: unable to open the store: %v\n", td.name, err)
+		}
+		if len(s.decisions) != td.replayed {
+			t.Fatalf("%s: expected %d decisions to be replayed, got %d\n", td.name, td.replayed, len(s.decisions))
+		}
+		d, err := s.Record(Confirmed, []string{"bob@example.com", "bobb@example.com"})
+		if err != nil {
+			t.Fatalf("%s: unable to record a decision: %v\n", td.name, err)
+		}
+		s.Close()
+
+		s, err = Open(path)
+		if err != nil {
+			t.Fatalf("%s: unable to reopen the store: %v\n", td.name, err)
+		}
+		if _, ok := s.Lookup(d.ClusterID); !ok || len(s.decisions) != td.replayed+1 {
+			t.Fatalf("%s: expected the decision recorded after the last line to be replayed\n", td.name)
+		}
+		s.Close()
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	_, err := Open("testdata/invalid.jsonl")
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Fatalf("expected the unknown decision on line 2 to be reported, got %v\n", err)
+	}
+}
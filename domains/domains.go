@@ -0,0 +1,162 @@
+// Package domains suggests corrections for email address domains that are
+// likely misspelled, such as gmial.com for gmail.com, by comparing them with a
+// reference set of known-good domains.
+package domains
+
+import (
+	"math"
+	"sort"
+
+	dupes "github.com/slpeople/duplicates"
+)
+
+const (
+	// DefaultTopDomains is how many of the most frequent domains among the
+	// people join the reference set.
+	DefaultTopDomains = 50
+	// DefaultMinCount is how many addresses a domain needs before it can
+	// join the reference set, so that a typo repeated once or twice is not
+	// taken for a real domain.
+	DefaultMinCount = 3
+
+	// longDomainLength is the length from which a domain may be two edits
+	// from the reference domain it is a typo of, rather than one. Short
+	// domains two edits apart, such as aol.com and aim.com, are too often
+	// both real.
+	longDomainLength = 12
+)
+
+type (
+	// Suggester suggests the reference domain a domain is likely a typo of.
+	// The reference set holds the most frequent domains it was built from
+	// and the popular domains. A Suggester is not modified after it is built
+	// and is safe for concurrent use.
+	Suggester struct {
+		counts    map[string]int
+		reference []string
+		known     map[string]bool
+		popular   map[string]bool
+	}
+	// Suggestion is the reference domain a domain is likely a typo of, and
+	// the Damerau-Levenshtein (optimal string alignment) distance between
+	// them.
+	Suggestion struct {
+		Domain   string `json:"domain"`
+		Distance int    `json:"distance"`
+	}
+	// candidate is a reference domain a domain may be a typo of, with how
+	// often it is used and its keyboard distance from the domain.
+	candidate struct {
+		Suggestion
+		uses int
+		typo float64
+	}
+)
+
+// NewSuggester builds a Suggester whose reference set holds the top most
+// frequent of domains that occur at least minCount times, together with
+// popular. domains lists a domain for every address, so that a domain occurs
+// as often as it is used.
+func NewSuggester(domains, popular []string, top, minCount int) *Suggester {
+	s := &Suggester{counts: make(map[string]int), known: make(map[string]bool), popular: make(map[string]bool, len(popular))}
+	for _, domain := range domains {
+		if domain != "" {
+			s.counts[domain]++
+		}
+	}
+	var frequent []string
+	for domain, count := range s.counts {
+		if count >= minCount {
+			frequent = append(frequent, domain)
+		}
+	}
+	sort.Slice(frequent, func(i, j int) bool {
+		if s.counts[frequent[i]] != s.counts[frequent[j]] {
+			return s.counts[frequent[i]] > s.counts[frequent[j]]
+		}
+		return frequent[i] < frequent[j]
+	})
+	if len(frequent) > top {
+		frequent = frequent[:top]
+	}
+	for _, domain := range popular {
+		s.popular[domain] = true
+	}
+	for _, domain := range append(frequent, popular...) {
+		if !s.known[domain] {
+			s.known[domain] = true
+			s.reference = append(s.reference, domain)
+		}
+	}
+	return s
+}
+
+// Known reports whether domain is in the reference set.
+func (s *Suggester) Known(domain string) bool {
+	return s.known[domain]
+}
+
+// Suggest returns the reference domain that domain is likely a typo of. That
+// is the closest reference domain one edit away, or two for long domains,
+// that is used more often than domain; popular domains count as used more
+// often than any other. Equally close domains are ranked by use and then by
+// how likely domain is to be a typo of them on a QWERTY keyboard. A frequent
+// domain may itself be a typo of a still more frequent one, but popular
+// domains are never typos. Suggest reports false when there is no likely
+// correction.
+func (s *Suggester) Suggest(domain string) (Suggestion, bool) {
+	if domain == "" || s.popular[domain] {
+		return Suggestion{}, false
+	}
+	var best *candidate
+	for _, reference := range s.reference {
+		if reference == domain {
+			continue
+		}
+		distance := dupes.ComputeOSADistance(domain, reference)
+		if distance > maxTypoDistance(reference) || s.uses(reference) <= s.counts[domain] {
+			continue
+		}
+		c := &candidate{
+			Suggestion: Suggestion{Domain: reference, Distance: distance},
+			uses:       s.uses(reference),
+			typo:       dupes.ComputeKeyboardDistance(domain, reference, dupes.QWERTY),
+		}
+		if best == nil || c.before(best) {
+			best = c
+		}
+	}
+	if best == nil {
+		return Suggestion{}, false
+	}
+	return best.Suggestion, true
+}
+
+// before reports whether c ranks before o as a correction.
+func (c *candidate) before(o *candidate) bool {
+	switch {
+	case c.Distance != o.Distance:
+		return c.Distance < o.Distance
+	case c.uses != o.uses:
+		return c.uses > o.uses
+	case c.typo != o.typo:
+		return c.typo < o.typo
+	}
+	return c.Domain < o.Domain
+}
+
+// uses returns how often domain occurs, popular domains occurring more than
+// any other.
+func (s *Suggester) uses(domain string) int {
+	if s.popular[domain] {
+		return math.MaxInt32
+	}
+	return s.counts[domain]
+}
+
+func maxTypoDistance(reference string) int {
+	if len(reference) >= longDomainLength {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,70 @@
+package domains
+
+import (
+	"testing"
+)
+
+func TestSuggest(t *testing.T) {
+	var observed []string
+	for domain, count := range map[string]int{
+		"salesloft.com":  5,
+		"salesloff.com":  1,
+		"example.com":    4,
+		"exampel.com":    3,
+		"rarecorp.com":   2,
+		"rarecorq.com":   1,
+		"gmial.com":      3,
+		"companyname.io": 3,
+	} {
+		for i := 0; i < count; i++ {
+			observed = append(observed, domain)
+		}
+	}
+	suggester := NewSuggester(observed, PopularDomains, DefaultTopDomains, DefaultMinCount)
+	suggestTestData := []struct {
+		domain   string
+		expected Suggestion
+		ok       bool
+	}{
+		{domain: "gmial.com", expected: Suggestion{Domain: "gmail.com", Distance: 1}, ok: true},
+		{domain: "yaho.com", expected: Suggestion{Domain: "yahoo.com", Distance: 1}, ok: true},
+		{domain: "hotmial.com", expected: Suggestion{Domain: "hotmail.com", Distance: 1}, ok: true},
+		{domain: "gmail.con", expected: Suggestion{Domain: "gmail.com", Distance: 1}, ok: true},
+		{domain: "salesloff.com", expected: Suggestion{Domain: "salesloft.com", Distance: 1}, ok: true},
+		// Long domains may be two edits away.
+		{domain: "compnyname.oi", expected: Suggestion{Domain: "companyname.io", Distance: 2}, ok: true},
+		// A domain used often enough to be a reference may still be a typo
+		// of one used more often, but not of one used less often.
+		{domain: "exampel.com", expected: Suggestion{Domain: "example.com", Distance: 1}, ok: true},
+		{domain: "example.com", ok: false},
+		// Nor of one used too rarely to be in the reference set.
+		{domain: "rarecorq.com", ok: false},
+		{domain: "gmail.com", ok: false},
+		{domain: "salesloft.com", ok: false},
+		{domain: "test.com", ok: false},
+		{domain: "", ok: false},
+	}
+	for _, td := range suggestTestData {
+		result, ok := suggester.Suggest(td.domain)
+		if ok != td.ok || result != td.expected {
+			t.Fatalf("unexpected suggestion for %q: \n\tresult: %#v %v\n\texpect: %#v %v\n", td.domain, result, ok, td.expected, td.ok)
+		}
+	}
+}
+
+func TestSuggestRanksByUse(t *testing.T) {
+	var observed []string
+	for i := 0; i < 3; i++ {
+		observed = append(observed, "acme.com", "acne.com", "acme.com")
+	}
+	suggester := NewSuggester(observed, nil, DefaultTopDomains, DefaultMinCount)
+	// acmf.com is one edit from both, but acme.com is used more often.
+	if result, ok := suggester.Suggest("acmf.com"); !ok || result.Domain != "acme.com" {
+		t.Fatalf("unexpected suggestion: %#v %v\n", result, ok)
+	}
+	// Only the most frequent domains join the reference set.
+	suggester = NewSuggester(observed, nil, 1, DefaultMinCount)
+	if suggester.Known("acne.com") || !suggester.Known("acme.com") {
+		t.Fatalf("expected only acme.com to be known\n")
+	}
+}
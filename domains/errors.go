@@ -0,0 +1,16 @@
+package domains
+
+import (
+	"github.com/go-chi/render"
+	"github.com/slpeople/errors"
+	slapi "github.com/slpeople/salesloftapi"
+)
+
+func ErrDomainTypos(err error) render.Renderer {
+	return &errors.ErrResponse{
+		Err:            err,
+		HTTPStatusCode: slapi.HTTPStatusCode(err),
+		StatusText:     "Error while finding misspelled email domains",
+		ErrorText:      err.Error(),
+	}
+}
@@ -0,0 +1,135 @@
+package domains
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/go-chi/render"
+	"github.com/slpeople/emails"
+	errors "github.com/slpeople/errors"
+	slapi "github.com/slpeople/salesloftapi"
+)
+
+type (
+	// DomainTypo is an email address of a person whose domain is likely
+	// misspelled. Field names the address's field, as in the SalesLoft API,
+	// and Domain is its domain in canonical form.
+	DomainTypo struct {
+		ID                    int    `json:"id"`
+		DisplayName           string `json:"displayName"`
+		Field                 string `json:"field"`
+		EmailAddress          string `json:"emailAddress"`
+		Domain                string `json:"domain"`
+		SuggestedDomain       string `json:"suggestedDomain"`
+		SuggestedEmailAddress string `json:"suggestedEmailAddress"`
+		Distance              int    `json:"distance"`
+	}
+	DomainTyposResponse struct {
+		DomainTypos []DomainTypo `json:"domainTypos"`
+	}
+	Handler struct {
+		source   slapi.PeopleSource
+		popular  []string
+		top      int
+		minCount int
+
+		mu          sync.Mutex
+		suggested   *slapi.People
+		domains     [][]string
+		suggestions map[string]Suggestion
+	}
+	// emailField is an email address field of a person.
+	emailField struct {
+		name    string
+		address func(*slapi.SimplifiedPersonView) string
+	}
+)
+
+var emailFields = []emailField{
+	{"email_address", func(p *slapi.SimplifiedPersonView) string { return p.EmailAddress }},
+	{"secondary_email_address", func(p *slapi.SimplifiedPersonView) string { return p.SecondaryEmailAddress }},
+	{"personal_email_address", func(p *slapi.SimplifiedPersonView) string { return p.PersonalEmailAddress }},
+}
+
+// NewHandler creates a Handler that finds likely misspelled domains among the
+// email addresses of the people provided by source, using the
+// DefaultTopDomains domains used at least DefaultMinCount times and the
+// PopularDomains as the reference set.
+func NewHandler(source slapi.PeopleSource) *Handler {
+	return &Handler{source: source, popular: PopularDomains, top: DefaultTopDomains, minCount: DefaultMinCount}
+}
+
+// DomainTyposHandler lists the email addresses, in any of a person's email
+// fields, whose domain is likely a typo of a reference domain, with the
+// suggested correction. Suggestions are worked out once per snapshot of
+// people.
+func (h *Handler) DomainTyposHandler(w http.ResponseWriter, r *http.Request) {
+	people, age, err := h.source.Snapshot(r.Context())
+	if err != nil {
+		render.Render(w, r, ErrDomainTypos(err))
+		return
+	}
+	slapi.SetSnapshotAge(w, age)
+	domains, suggestions := h.snapshotSuggestions(people)
+	response := &DomainTyposResponse{DomainTypos: []DomainTypo{}}
+	for i := range *people {
+		person := &(*people)[i]
+		for j, field := range emailFields {
+			suggestion, ok := suggestions[domains[i][j]]
+			if !ok {
+				continue
+			}
+			emailAddress := field.address(person)
+			local, _, _ := emails.Split(strings.TrimSpace(emailAddress))
+			response.DomainTypos = append(response.DomainTypos, DomainTypo{
+				ID:                    person.ID,
+				DisplayName:           person.DisplayName,
+				Field:                 field.name,
+				EmailAddress:          emailAddress,
+				Domain:                domains[i][j],
+				SuggestedDomain:       suggestion.Domain,
+				SuggestedEmailAddress: local + "@" + suggestion.Domain,
+				Distance:              suggestion.Distance,
+			})
+		}
+	}
+	if err := render.Render(w, r, response); err != nil {
+		render.Render(w, r, errors.ErrRender(err))
+		return
+	}
+}
+
+// snapshotSuggestions returns the canonical domain of each of people's email
+// fields, and the suggested correction of each domain that is likely a typo.
+// Each distinct domain is looked up once, and both are reused until the
+// source returns a different snapshot.
+func (h *Handler) snapshotSuggestions(people *slapi.People) ([][]string, map[string]Suggestion) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.suggested == people {
+		return h.domains, h.suggestions
+	}
+	domains := make([][]string, len(*people))
+	var all []string
+	for i := range *people {
+		domains[i] = make([]string, len(emailFields))
+		for j, field := range emailFields {
+			_, domains[i][j], _ = emails.Split(emails.Canonicalize(field.address(&(*people)[i])))
+			all = append(all, domains[i][j])
+		}
+	}
+	suggester := NewSuggester(all, h.popular, h.top, h.minCount)
+	suggestions := make(map[string]Suggestion)
+	for domain := range suggester.counts {
+		if suggestion, ok := suggester.Suggest(domain); ok {
+			suggestions[domain] = suggestion
+		}
+	}
+	h.suggested, h.domains, h.suggestions = people, domains, suggestions
+	return domains, suggestions
+}
+
+func (dt *DomainTyposResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
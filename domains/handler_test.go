@@ -0,0 +1,84 @@
+package domains
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	slapi "github.com/slpeople/salesloftapi"
+)
+
+func TestDomainTyposHandler(t *testing.T) {
+	source := slapi.PeopleSourceFunc(func(ctx context.Context) (*slapi.People, time.Duration, error) {
+		return &slapi.People{
+			{ID: 1, DisplayName: "Dan Test", EmailAddress: "dan@salesloft.com", PersonalEmailAddress: "Dan.Test@GMIAL.com"},
+			{ID: 2, DisplayName: "Dave Test", EmailAddress: "dave@salesloft.com"},
+			{ID: 3, DisplayName: "Dana Test", EmailAddress: "dana@salesloft.com", SecondaryEmailAddress: "dana@yaho.com"},
+			{ID: 4, DisplayName: "Dean Test", EmailAddress: "dean@saleslof.com"},
+		}, 0, nil
+	})
+	w := httptest.NewRecorder()
+	NewHandler(source).DomainTyposHandler(w, httptest.NewRequest("GET", "/people/emails/domain-typos", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d\n", w.Code)
+	}
+	var result DomainTyposResponse
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("unable to decode the response: %v\n", err)
+	}
+	expected := []DomainTypo{
+		{ID: 1, DisplayName: "Dan Test", Field: "personal_email_address", EmailAddress: "Dan.Test@GMIAL.com", Domain: "gmial.com", SuggestedDomain: "gmail.com", SuggestedEmailAddress: "Dan.Test@gmail.com", Distance: 1},
+		{ID: 3, DisplayName: "Dana Test", Field: "secondary_email_address", EmailAddress: "dana@yaho.com", Domain: "yaho.com", SuggestedDomain: "yahoo.com", SuggestedEmailAddress: "dana@yahoo.com", Distance: 1},
+		{ID: 4, DisplayName: "Dean Test", Field: "email_address", EmailAddress: "dean@saleslof.com", Domain: "saleslof.com", SuggestedDomain: "salesloft.com", SuggestedEmailAddress: "dean@salesloft.com", Distance: 1},
+	}
+	if !cmp.Equal(result.DomainTypos, expected) {
+		t.Fatalf("unexpected domain typos: \n\tresult: %#v\n\texpect: %#v\n", result.DomainTypos, expected)
+	}
+}
+
+// Suggestions are worked out once per snapshot, and again once the source
+// returns a new one.
+func TestDomainTyposHandlerCachesPerSnapshot(t *testing.T) {
+	snapshot := &slapi.People{{ID: 1, EmailAddress: "dan@gmial.com"}, {ID: 2, EmailAddress: "dave@gmial.com"}}
+	source := slapi.PeopleSourceFunc(func(ctx context.Context) (*slapi.People, time.Duration, error) {
+		return snapshot, 0, nil
+	})
+	h := NewHandler(source)
+	typos := func() []DomainTypo {
+		w := httptest.NewRecorder()
+		h.DomainTyposHandler(w, httptest.NewRequest("GET", "/people/emails/domain-typos", nil))
+		var result DomainTyposResponse
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatalf("unable to decode the response: %v\n", err)
+		}
+		return result.DomainTypos
+	}
+	if result := typos(); len(result) != 2 || result[0].SuggestedDomain != "gmail.com" {
+		t.Fatalf("unexpected domain typos: %#v\n", result)
+	}
+	_, suggestions := h.snapshotSuggestions(snapshot)
+	if len(suggestions) != 1 {
+		t.Fatalf("expected gmial.com to be looked up once, got suggestions %v\n", suggestions)
+	}
+
+	snapshot = &slapi.People{{ID: 1, EmailAddress: "dan@gmail.com"}}
+	if result := typos(); len(result) != 0 {
+		t.Fatalf("expected a new snapshot to be looked up afresh, got %#v\n", result)
+	}
+}
+
+func TestDomainTyposHandlerSourceError(t *testing.T) {
+	source := slapi.PeopleSourceFunc(func(ctx context.Context) (*slapi.People, time.Duration, error) {
+		return nil, 0, &slapi.RetriesExhaustedError{Attempts: 3, Err: errors.New("unavailable")}
+	})
+	w := httptest.NewRecorder()
+	NewHandler(source).DomainTyposHandler(w, httptest.NewRequest("GET", "/people/emails/domain-typos", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected a 503 when the source is unavailable, got %d\n", w.Code)
+	}
+}
@@ -0,0 +1,52 @@
+package domains
+
+// PopularDomains are the domains of widely used mail providers. They are
+// always part of a Suggester's reference set, however rarely they appear
+// among the people, as a typo of one of them is far likelier than a new
+// provider with a similar name.
+var PopularDomains = []string{
+	"163.com",
+	"aim.com",
+	"aol.com",
+	"att.net",
+	"bellsouth.net",
+	"btinternet.com",
+	"comcast.net",
+	"cox.net",
+	"earthlink.net",
+	"fastmail.com",
+	"gmail.com",
+	"gmx.com",
+	"gmx.de",
+	"gmx.net",
+	"googlemail.com",
+	"hey.com",
+	"hotmail.co.uk",
+	"hotmail.com",
+	"hotmail.fr",
+	"icloud.com",
+	"libero.it",
+	"live.com",
+	"mac.com",
+	"mail.com",
+	"mail.ru",
+	"me.com",
+	"msn.com",
+	"orange.fr",
+	"outlook.com",
+	"pm.me",
+	"proton.me",
+	"protonmail.com",
+	"qq.com",
+	"rocketmail.com",
+	"sbcglobal.net",
+	"verizon.net",
+	"web.de",
+	"yahoo.co.uk",
+	"yahoo.com",
+	"yahoo.fr",
+	"yandex.com",
+	"yandex.ru",
+	"ymail.com",
+	"zoho.com",
+}
@@ -0,0 +1,69 @@
+package duplicates
+
+// The kinds of EditOperation.
+const (
+	Substitute = "substitute"
+	Insert     = "insert"
+	Delete     = "delete"
+)
+
+type (
+	// EditOperation is one step in turning one string into another. Position
+	// is the position, in runes, in the first string at which From is
+	// replaced by To, To is inserted before, or From is deleted.
+	EditOperation struct {
+		Op       string `json:"op"`
+		Position int    `json:"position"`
+		From     string `json:"from,omitempty"`
+		To       string `json:"to,omitempty"`
+	}
+)
+
+// ComputeAlignment returns the edits of a shortest Levenshtein alignment of a
+// with b, in order of position, so that there are ComputeDistance(a, b) of
+// them. Of equally short alignments, substitutions are preferred, and then
+// deletions.
+func ComputeAlignment(a, b string) []EditOperation {
+	s1, s2 := []rune(a), []rune(b)
+	// distances[i][j] is the distance between the first i runes of a and
+	// the first j runes of b.
+	distances := make([][]int, len(s1)+1)
+	for i := range distances {
+		distances[i] = make([]int, len(s2)+1)
+		distances[i][0] = i
+	}
+	for j := range distances[0] {
+		distances[0][j] = j
+	}
+	for i := 1; i <= len(s1); i++ {
+		for j := 1; j <= len(s2); j++ {
+			cost := 1
+			if s1[i-1] == s2[j-1] {
+				cost = 0
+			}
+			distances[i][j] = min(distances[i-1][j-1]+cost, min(distances[i-1][j]+1, distances[i][j-1]+1))
+		}
+	}
+
+	// Trace the alignment back from the end of both strings.
+	edits := []EditOperation{}
+	for i, j := len(s1), len(s2); i > 0 || j > 0; {
+		switch {
+		case i > 0 && j > 0 && s1[i-1] == s2[j-1] && distances[i][j] == distances[i-1][j-1]:
+			i, j = i-1, j-1
+		case i > 0 && j > 0 && distances[i][j] == distances[i-1][j-1]+1:
+			edits = append(edits, EditOperation{Op: Substitute, Position: i - 1, From: string(s1[i-1]), To: string(s2[j-1])})
+			i, j = i-1, j-1
+		case i > 0 && distances[i][j] == distances[i-1][j]+1:
+			edits = append(edits, EditOperation{Op: Delete, Position: i - 1, From: string(s1[i-1])})
+			i--
+		default:
+			edits = append(edits, EditOperation{Op: Insert, Position: i, To: string(s2[j-1])})
+			j--
+		}
+	}
+	for l, r := 0, len(edits)-1; l < r; l, r = l+1, r-1 {
+		edits[l], edits[r] = edits[r], edits[l]
+	}
+	return edits
+}
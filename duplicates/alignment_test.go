@@ -0,0 +1,40 @@
+package duplicates
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestComputeAlignment(t *testing.T) {
+	alignmentTestData := []struct {
+		a, b     string
+		expected []EditOperation
+	}{
+		{a: "dan", b: "dan", expected: []EditOperation{}},
+		{a: "", b: "", expected: []EditOperation{}},
+		{a: "dan", b: "dam", expected: []EditOperation{{Op: Substitute, Position: 2, From: "n", To: "m"}}},
+		// Of the two ways to insert a repeated character, the first is found.
+		{a: "dan", b: "dann", expected: []EditOperation{{Op: Insert, Position: 2, To: "n"}}},
+		{a: "dann", b: "dan", expected: []EditOperation{{Op: Delete, Position: 2, From: "n"}}},
+		{a: "", b: "ab", expected: []EditOperation{{Op: Insert, Position: 0, To: "a"}, {Op: Insert, Position: 0, To: "b"}}},
+		{a: "ab", b: "", expected: []EditOperation{{Op: Delete, Position: 0, From: "a"}, {Op: Delete, Position: 1, From: "b"}}},
+		{a: "kitten", b: "sitting", expected: []EditOperation{
+			{Op: Substitute, Position: 0, From: "k", To: "s"},
+			{Op: Substitute, Position: 4, From: "e", To: "i"},
+			{Op: Insert, Position: 6, To: "g"},
+		}},
+		// Positions are counted in characters, not bytes.
+		{a: "josé@test.com", b: "jose@test.com", expected: []EditOperation{{Op: Substitute, Position: 3, From: "é", To: "e"}}},
+		{a: "dan@test.com", b: "dan@tesst.com", expected: []EditOperation{{Op: Insert, Position: 6, To: "s"}}},
+	}
+	for _, td := range alignmentTestData {
+		result := ComputeAlignment(td.a, td.b)
+		if !cmp.Equal(result, td.expected) {
+			t.Fatalf("unexpected alignment of %q with %q: \n\tresult: %+v\n\texpect: %+v\n", td.a, td.b, result, td.expected)
+		}
+		if distance := ComputeDistance(td.a, td.b); len(result) != distance {
+			t.Fatalf("the alignment of %q with %q has %d edits for a distance of %d\n", td.a, td.b, len(result), distance)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package duplicates
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"github.com/slpeople/characters"
+)
+
+// Lower bounds on the Levenshtein distance between two strings, cheap enough
+// to rule out a pair before its distance is computed. Every insertion,
+// deletion or substitution adds at most one to the characters one string has
+// more of than the other, and takes at most one from those it has fewer of,
+// so neither can exceed the distance.
+
+// lengthBound returns the difference in length, in runes, between a and b,
+// which is never more than their Levenshtein distance.
+func lengthBound(a, b string) int {
+	difference := utf8.RuneCountInString(a) - utf8.RuneCountInString(b)
+	if difference < 0 {
+		return -difference
+	}
+	return difference
+}
+
+// histogramBound returns the larger of how many characters, counted with
+// their repetitions, the string with the histogram a has more of than the
+// string with the histogram b, and how many it has fewer of. It is never more
+// than the strings' Levenshtein distance, and never less than their difference
+// in length.
+func histogramBound(a, b histogram) int {
+	surplus, deficit := 0, 0
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case j == len(b) || (i < len(a) && a[i].char < b[j].char):
+			surplus += a[i].count
+			i++
+		case i == len(a) || b[j].char < a[i].char:
+			deficit += b[j].count
+			j++
+		default:
+			if d := a[i].count - b[j].count; d > 0 {
+				surplus += d
+			} else {
+				deficit -= d
+			}
+			i++
+			j++
+		}
+	}
+	return max(surplus, deficit)
+}
+
+type (
+	// histogram is the character frequencies of a string ordered by
+	// character, so that two can be compared in a single pass.
+	histogram []charCount
+	charCount struct {
+		char  string
+		count int
+	}
+	// histograms lazily counts the characters of indexed strings, so that
+	// each string is only counted once however many pairs it is in.
+	histograms struct {
+		entries    []string
+		histograms []histogram
+	}
+)
+
+func newHistogram(s string) histogram {
+	frequencies := characters.CharacterFrequencyCount(s, nil)
+	h := make(histogram, 0, len(frequencies))
+	for char, count := range frequencies {
+		h = append(h, charCount{char: char, count: count})
+	}
+	sort.Slice(h, func(i, j int) bool { return h[i].char < h[j].char })
+	return h
+}
+
+func newHistograms(entries []string) *histograms {
+	return &histograms{entries: entries, histograms: make([]histogram, len(entries))}
+}
+
+func (h *histograms) of(position int) histogram {
+	if h.histograms[position] == nil {
+		h.histograms[position] = newHistogram(h.entries[position])
+	}
+	return h.histograms[position]
+}
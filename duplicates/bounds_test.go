@@ -0,0 +1,74 @@
+package duplicates
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestCompareLengths(t *testing.T) {
+	compareLengthsTestData := []struct {
+		a, b      string
+		threshold int
+		expected  bool
+	}{
+		{a: "dan", b: "dan", threshold: 0, expected: true},
+		{a: "dan", b: "dann", threshold: 0, expected: false},
+		{a: "dan", b: "dann", threshold: 1, expected: true},
+		{a: "dann", b: "dan", threshold: 1, expected: true},
+		// A difference below the threshold is within it too.
+		{a: "dan", b: "dann", threshold: 2, expected: true},
+		{a: "dan", b: "danny", threshold: 2, expected: true},
+		{a: "dan", b: "danny", threshold: 1, expected: false},
+		{a: "danny", b: "dan", threshold: 1, expected: false},
+		{a: "", b: "dan", threshold: 3, expected: true},
+		{a: "", b: "dan", threshold: 2, expected: false},
+		// Lengths are counted in characters, not bytes.
+		{a: "josé", b: "jose", threshold: 0, expected: true},
+	}
+	for _, td := range compareLengthsTestData {
+		if result := compareLengths(td.a, td.b, td.threshold); result != td.expected {
+			t.Fatalf("unexpected length comparison of %q and %q within %d: \n\tresult: %v\n\texpect: %v\n", td.a, td.b, td.threshold, result, td.expected)
+		}
+	}
+}
+
+func TestLowerBounds(t *testing.T) {
+	boundsTestData := []struct {
+		a, b      string
+		length    int
+		histogram int
+	}{
+		{a: "dan", b: "dan", length: 0, histogram: 0},
+		// A transposition keeps the characters.
+		{a: "dan", b: "dna", length: 0, histogram: 0},
+		{a: "dan", b: "dam", length: 0, histogram: 1},
+		{a: "dan", b: "dann", length: 1, histogram: 1},
+		{a: "dan", b: "mike", length: 1, histogram: 4},
+		{a: "mike", b: "dan", length: 1, histogram: 4},
+		{a: "aab", b: "abb", length: 0, histogram: 1},
+		{a: "", b: "dan", length: 3, histogram: 3},
+		{a: "josé", b: "jose", length: 0, histogram: 1},
+	}
+	for _, td := range boundsTestData {
+		length := lengthBound(td.a, td.b)
+		histogram := histogramBound(newHistogram(td.a), newHistogram(td.b))
+		if length != td.length || histogram != td.histogram {
+			t.Fatalf("unexpected bounds for %q and %q: \n\tresult: %d %d\n\texpect: %d %d\n", td.a, td.b, length, histogram, td.length, td.histogram)
+		}
+		if distance := ComputeDistance(td.a, td.b); length > distance || histogram > distance || histogram < length {
+			t.Fatalf("the bounds for %q and %q are not between their difference in length and their distance %d: %d %d\n", td.a, td.b, distance, length, histogram)
+		}
+	}
+}
+
+// Comparing every pair, as for distances an index was not built for, rules
+// out pairs by their character counts without changing what is found.
+func TestIndexPossibleDuplicatesPrunesByCharacters(t *testing.T) {
+	strs := []string{"dan@test.com", "dna@test.com", "dann@test.com", "nad@test.com", "mike@acme.com", "daniel@test.com"}
+	settings := Settings{DistanceThreshold: 3, LengthThreshold: 3, MinClusterSize: 2}
+	expected := PossibleDuplicates{{"dan@test.com", "dna@test.com", "dann@test.com", "nad@test.com", "daniel@test.com"}}
+	if result := NewIndex(strs, 1).PossibleDuplicates(settings); !cmp.Equal(result, expected) {
+		t.Fatalf("unexpected duplicates: \n\tresult: %#v\n\texpect: %#v\n", result, expected)
+	}
+}
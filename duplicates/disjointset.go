@@ -0,0 +1,76 @@
+package duplicates
+
+// disjointSet is a union-find structure over the indices 0..n-1, used to merge
+// pairs of possible duplicates into transitive clusters.
+type disjointSet struct {
+	parent []int
+	rank   []int
+}
+
+func newDisjointSet(n int) *disjointSet {
+	d := &disjointSet{parent: make([]int, n), rank: make([]int, n)}
+	for i := range d.parent {
+		d.parent[i] = i
+	}
+	return d
+}
+
+// find returns the representative of i's set, compressing the path to it.
+func (d *disjointSet) find(i int) int {
+	for d.parent[i] != i {
+		d.parent[i] = d.parent[d.parent[i]]
+		i = d.parent[i]
+	}
+	return i
+}
+
+func (d *disjointSet) union(i, j int) {
+	ri, rj := d.find(i), d.find(j)
+	if ri == rj {
+		return
+	}
+	switch {
+	case d.rank[ri] < d.rank[rj]:
+		d.parent[ri] = rj
+	case d.rank[ri] > d.rank[rj]:
+		d.parent[rj] = ri
+	default:
+		d.parent[rj] = ri
+		d.rank[ri]++
+	}
+}
+
+// sets returns the members of every set with more than one member. Each set
+// lists its members in order and sets are ordered by their lowest member.
+func (d *disjointSet) sets() [][]int {
+	members := make(map[int][]int)
+	var roots []int
+	for i := range d.parent {
+		root := d.find(i)
+		if _, ok := members[root]; !ok {
+			roots = append(roots, root)
+		}
+		members[root] = append(members[root], i)
+	}
+	sets := [][]int{}
+	for _, root := range roots {
+		if len(members[root]) > 1 {
+			sets = append(sets, members[root])
+		}
+	}
+	return sets
+}
+
+// positionsToStrings replaces each position in sets with the string at that
+// position in strs.
+func positionsToStrings(sets [][]int, strs []string) PossibleDuplicates {
+	groups := make(PossibleDuplicates, 0, len(sets))
+	for _, set := range sets {
+		group := make([]string, len(set))
+		for i, position := range set {
+			group[i] = strs[position]
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
@@ -1,89 +1,175 @@
 package duplicates
 
 import (
-	"sort"
-	"strings"
+	"encoding/json"
+	"fmt"
 	"unicode/utf8"
 
-	chars "github.com/slpeople/characters"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 type (
 	PossibleDuplicates [][]string
-	thresholdSettings  struct {
-		distanceThreshold int
-		lengthThreshold   int
+	// Settings select how email addresses are compared and which of their
+	// clusters are reported.
+	Settings struct {
+		// DistanceThreshold is the largest distance at which addresses are
+		// possible duplicates under distance metrics.
+		DistanceThreshold int `json:"distance"`
+		// LengthThreshold is the largest difference in length at which
+		// addresses are compared.
+		LengthThreshold int `json:"length"`
+		// MinClusterSize is the fewest addresses a reported cluster holds.
+		MinClusterSize int `json:"minClusterSize"`
+		// Metric defaults to Levenshtein. Distance metrics use the
+		// DistanceThreshold, or the Threshold when it is set, and similarity
+		// metrics the SimilarityThreshold, or their default threshold when it
+		// is zero.
+		Metric              Metric  `json:"-"`
+		SimilarityThreshold float64 `json:"similarityThreshold"`
+		// Threshold, when set, replaces the DistanceThreshold, so that metrics
+		// whose scores need not be whole numbers, such as Keyboard, can be
+		// given a threshold that is not either.
+		Threshold *float64 `json:"-"`
+		// LocalThreshold and DomainThreshold, when set, are the thresholds
+		// the local parts and the domains of two addresses must each be
+		// within, in the metric's units, besides the whole addresses being
+		// within the metric's threshold.
+		LocalThreshold  *float64 `json:"localThreshold"`
+		DomainThreshold *float64 `json:"domainThreshold"`
 	}
 )
 
-/*** Level 3: Duplicate Email Addresses ***/
-// References:
-//  >> https://stackoverflow.com/questions/577463/finding-how-similar-two-strings-are
-//  >> https://github.com/agnivade/levenshtein/blob/master/levenshtein.go
-//  >> https://gist.github.com/andrei-m/982927#gistcomment-1931258
-
-// FindPossbileDuplicates will search for possible duplicate strings that were for instance
-// generated due to a typo upon input. The provide slice of strings is first grouped
-// into slices that contain the same characters.
-// Then these groups are then analzyed to see which strings could be duplicates
-// of each other. Each string is compared to every other string. During comparison
-// if the lengths of the two strings are equal or one is greater than the other
-// plus a threshold value, then those strings will then have their Levenshtein
-// distance computed. If the Levenshtein distance is less than threshold, then
-// the two strings are considered possible duplicates and candidate for review.
-// At the point the strings have the same characters, are similar length, and do
-// not require many operations to convert one string to the other.
-func FindPossibleDuplicates(strs []string, settings thresholdSettings) PossibleDuplicates {
-	anagrams := make(map[string][]string)
-	for _, s := range strs {
-		charFreq := chars.CharacterFrequencyCount(s, nil)
-		uniqueChars := make([]string, len(charFreq))
-		for ch := range charFreq {
-			uniqueChars = append(uniqueChars, ch)
-		}
-		sort.Strings(uniqueChars)
-		uniqueCharsStr := strings.Join(uniqueChars, "")
-		if dupeList, ok := anagrams[uniqueCharsStr]; ok {
-			anagrams[uniqueCharsStr] = append(dupeList, s)
-		} else {
-			anagrams[uniqueCharsStr] = []string{s}
-		}
+// DefaultSettings compare addresses by Levenshtein distance and report pairs
+// one edit apart.
+var DefaultSettings = Settings{DistanceThreshold: 1, LengthThreshold: 1, MinClusterSize: 2}
+
+// maxIndexDistance is the largest distance an Index is built for, as the
+// index grows too large beyond it. Settings whose metric allows more edits
+// compare every pair of strings instead, skipping the pairs whose lengths or
+// character counts are already too far apart.
+const maxIndexDistance = 2
+
+// Validate reports whether the settings can be used to find possible
+// duplicates.
+func (s Settings) Validate() error {
+	metric, _ := s.metricThreshold()
+	switch {
+	case s.DistanceThreshold < 0:
+		return fmt.Errorf("the distance threshold must not be negative, got %d", s.DistanceThreshold)
+	case s.LengthThreshold < 0:
+		return fmt.Errorf("the length threshold must not be negative, got %d", s.LengthThreshold)
+	case s.MinClusterSize < 2:
+		return fmt.Errorf("the minimum cluster size must be at least 2, got %d", s.MinClusterSize)
+	}
+	// A zero similarity threshold selects the metric's default.
+	similarityThreshold := &s.SimilarityThreshold
+	if !metric.Similarity() || s.SimilarityThreshold == 0 {
+		similarityThreshold = nil
 	}
-	duplicates := PossibleDuplicates{}
-	for _, dupeList := range anagrams {
-		// If there is only 1 string, then skip it.
-		if len(dupeList) < 2 {
+	distanceThreshold := s.Threshold
+	if metric.Similarity() {
+		distanceThreshold = nil
+	}
+	for _, threshold := range []*float64{similarityThreshold, distanceThreshold, s.LocalThreshold, s.DomainThreshold} {
+		if threshold == nil {
 			continue
 		}
-		for i := 0; i < len(strs); i++ {
-			dupes := []string{strs[i]}
-			for j := i + 1; j < len(strs); j++ {
-				if !compareLengths(strs[i], strs[j], settings.lengthThreshold) {
-					continue
-				}
-				if ComputeDistance(strs[i], strs[j]) > settings.distanceThreshold {
-					continue
-				}
-				dupes = append(dupes, strs[j])
-			}
-			if len(dupes) > 1 {
-				duplicates = append(duplicates, dupes)
-			}
+		if err := ValidateThreshold(metric, *threshold); err != nil {
+			return err
 		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes the settings together with the name of their metric and
+// the threshold applied to its scores.
+func (s Settings) MarshalJSON() ([]byte, error) {
+	// settings has the fields of Settings without its methods, so that it is
+	// encoded as a plain struct.
+	type settings Settings
+	metric, threshold := s.metricThreshold()
+	return json.Marshal(struct {
+		settings
+		Metric    string  `json:"metric"`
+		Threshold float64 `json:"threshold"`
+	}{settings(s), metric.Name(), threshold})
+}
 
+// metricThreshold returns the metric to compare strings with and the
+// threshold to apply to its scores.
+func (s Settings) metricThreshold() (Metric, float64) {
+	metric := s.Metric
+	if metric == nil {
+		metric = levenshteinMetric{}
+	}
+	if !metric.Similarity() {
+		if s.Threshold != nil {
+			return metric, *s.Threshold
+		}
+		return metric, float64(s.DistanceThreshold)
+	}
+	if s.SimilarityThreshold > 0 {
+		return metric, s.SimilarityThreshold
 	}
+	return metric, DefaultThreshold(metric, s.DistanceThreshold)
+}
 
-	return duplicates
+// withinPartThresholds reports whether the scores of the local parts and the
+// domains of two addresses are within the part thresholds that are set.
+func (s Settings) withinPartThresholds(local, domain float64) bool {
+	metric, _ := s.metricThreshold()
+	if s.LocalThreshold != nil && !withinThreshold(metric, local, *s.LocalThreshold) {
+		return false
+	}
+	return s.DomainThreshold == nil || withinThreshold(metric, domain, *s.DomainThreshold)
 }
 
-func compareLengths(str1, str2 string, threshold int) bool {
-	if len(str1) == len(str2) {
-		return true
+// maxEdits returns the largest Levenshtein distance at which strings can
+// match under the settings, and false when the metric does not limit it.
+func (s Settings) maxEdits() (int, bool) {
+	metric, threshold := s.metricThreshold()
+	if bounded, ok := metric.(editBounded); ok {
+		return bounded.maxEdits(threshold), true
 	}
-	if len(str1) > len(str2) {
-		return (len(str1) - len(str2) - threshold) == 0
+	return 0, false
+}
+
+/*** Level 3: Duplicate Email Addresses ***/
+// References:
+//  >> https://stackoverflow.com/questions/577463/finding-how-similar-two-strings-are
+//  >> https://github.com/agnivade/levenshtein/blob/master/levenshtein.go
+//  >> https://gist.github.com/andrei-m/982927#gistcomment-1931258
+
+// FindPossibleDuplicates will search for possible duplicate strings that were
+// for instance generated due to a typo upon input. Only candidate pairs are
+// compared: strings sharing a variant with up to the distance threshold of
+// their characters deleted (see Index), or, for similarity metrics, enough
+// tokens to reach the threshold. A pair whose lengths differ by at most the
+// length threshold is a possible duplicate when its Levenshtein distance, or
+// its score under the settings' metric, is within the threshold, and so are
+// the scores of its local parts and domains under the part thresholds, when
+// set (see scoreParts). Clusters smaller than MinClusterSize are left out.
+//
+// Possible duplicates are merged transitively: if a matches b and b matches c,
+// then a, b and c are reported together as one cluster even if a and c are too
+// far apart to match directly. Each cluster is reported once, with its strings
+// in input order, and clusters are ordered by their first string. Empty
+// strings are ignored.
+func FindPossibleDuplicates(strs []string, settings Settings) PossibleDuplicates {
+	defer prometheus.NewTimer(detectionDuration).ObserveDuration()
+	maxEdits, _ := settings.maxEdits()
+	if maxEdits > maxIndexDistance {
+		// The index's variants will not be searched.
+		maxEdits = 0
 	}
-	return (len(str2) - len(str1) - threshold) == 0
+	return NewIndex(strs, maxEdits).PossibleDuplicates(settings)
+}
+
+// compareLengths reports whether the lengths of str1 and str2, in runes,
+// differ by at most threshold.
+func compareLengths(str1, str2 string, threshold int) bool {
+	return lengthBound(str1, str2) <= threshold
 }
 
 /***
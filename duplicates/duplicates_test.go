@@ -1,6 +1,8 @@
 package duplicates
 
 import (
+	"fmt"
+	"math/rand"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -17,11 +19,31 @@ func TestFindPossibleDuplicates(t *testing.T) {
 				{"dan@test.com", "dann@test.com"},
 			},
 		},
+		{
+			// Matches are merged transitively even though the first and last
+			// addresses are too far apart to match directly.
+			strings: []string{"dannn@test.com", "dave@test.com", "dan@test.com", "dann@test.com"},
+			expected: [][]string{
+				{"dannn@test.com", "dan@test.com", "dann@test.com"},
+			},
+		},
+		{
+			// Each cluster is reported once.
+			strings: []string{"ab@x.io", "abb@x.io", "cd@y.io", "cdd@y.io", "ef@z.io"},
+			expected: [][]string{
+				{"ab@x.io", "abb@x.io"},
+				{"cd@y.io", "cdd@y.io"},
+			},
+		},
+		{
+			// Substitutions are found as well as insertions and deletions.
+			strings: []string{"dan@test.com", "dam@test.com", "", ""},
+			expected: [][]string{
+				{"dan@test.com", "dam@test.com"},
+			},
+		},
 	}
-	settings := thresholdSettings{
-		distanceThreshold: 1,
-		lengthThreshold:   1,
-	}
+	settings := DefaultSettings
 
 	for _, td := range duplicateTestData {
 		result := FindPossibleDuplicates(td.strings, settings)
@@ -383,4 +405,59 @@ func TestFindPossibleDuplicates(t *testing.T) {
 	for i, dps := range result {
 		t.Logf("Result : %d : \n\t%#v\n", i, dps)
 	}
+	seen := map[string]bool{}
+	for _, dps := range result {
+		for _, s := range dps {
+			if seen[s] {
+				t.Fatalf("%q was reported in more than one cluster\n", s)
+			}
+			seen[s] = true
+		}
+	}
+}
+
+// syntheticEmailAddresses generates n addresses from a fixed seed, roughly one
+// in ten of which repeats an earlier address with a doubled character.
+func syntheticEmailAddresses(n int) []string {
+	names := []string{"dan", "ana", "maria", "jose", "li", "wei", "anne", "john", "sara", "omar", "yuki", "ivan"}
+	domains := []string{"gmail.com", "yahoo.com", "outlook.com", "salesloft.com", "test.io", "example.org"}
+	random := rand.New(rand.NewSource(1))
+	addresses := make([]string, 0, n)
+	for len(addresses) < n {
+		if len(addresses) > 0 && random.Intn(10) == 0 {
+			s := addresses[random.Intn(len(addresses))]
+			i := random.Intn(len(s))
+			addresses = append(addresses, s[:i+1]+s[i:])
+			continue
+		}
+		addresses = append(addresses, fmt.Sprintf("%s.%s%d@%s",
+			names[random.Intn(len(names))], names[random.Intn(len(names))], random.Intn(1000), domains[random.Intn(len(domains))]))
+	}
+	return addresses
+}
+
+func benchmarkFindPossibleDuplicates(b *testing.B, n int, metric Metric) {
+	addresses := syntheticEmailAddresses(n)
+	settings := DefaultSettings
+	settings.Metric = metric
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		FindPossibleDuplicates(addresses, settings)
+	}
+}
+
+func BenchmarkFindPossibleDuplicates10k(b *testing.B) {
+	benchmarkFindPossibleDuplicates(b, 10000, nil)
+}
+
+func BenchmarkFindPossibleDuplicates100k(b *testing.B) {
+	benchmarkFindPossibleDuplicates(b, 100000, nil)
+}
+
+func BenchmarkFindPossibleDuplicatesJaroWinkler10k(b *testing.B) {
+	benchmarkFindPossibleDuplicates(b, 10000, jaroWinklerMetric{})
+}
+
+func BenchmarkFindPossibleDuplicatesJaccard10k(b *testing.B) {
+	benchmarkFindPossibleDuplicates(b, 10000, jaccardMetric{q: 2})
 }
@@ -1,14 +1,29 @@
 package duplicates
 
 import (
+	goerrors "errors"
+	"net/http"
+
 	"github.com/go-chi/render"
 	"github.com/slpeople/errors"
+	slapi "github.com/slpeople/salesloftapi"
 )
 
+var errMissingEmail = goerrors.New("the email query parameter is required")
+
+func ErrDecision(err error) render.Renderer {
+	return &errors.ErrResponse{
+		Err:            err,
+		HTTPStatusCode: http.StatusInternalServerError,
+		StatusText:     "Error while recording the decision",
+		ErrorText:      err.Error(),
+	}
+}
+
 func ErrDuplicates(err error) render.Renderer {
 	return &errors.ErrResponse{
 		Err:            err,
-		HTTPStatusCode: 500,
+		HTTPStatusCode: slapi.HTTPStatusCode(err),
 		StatusText:     "Error while finding possible duplicate email addresses",
 		ErrorText:      err.Error(),
 	}
@@ -0,0 +1,159 @@
+package duplicates
+
+import (
+	"unicode/utf8"
+
+	"github.com/slpeople/decisions"
+	slapi "github.com/slpeople/salesloftapi"
+)
+
+// maxExplainedPairs is the largest cluster whose every pair of members is
+// explained. Larger clusters, whose pairs grow quadratically, only explain the
+// pairs they were merged from.
+const maxExplainedPairs = 50
+
+type (
+	// ExplainedCluster is a cluster of possible duplicate email addresses with
+	// the people they belong to, the metric and threshold they were matched
+	// under, and how alike each pair of them is. Confidence, between 0 and 1,
+	// is the mean similarity of the pairs, so that a cluster chained together
+	// from addresses that are each close to the next, but far from the
+	// others, is less certain than one whose addresses are all close. ID
+	// identifies the cluster by its canonical addresses for a review decision,
+	// and Decision is the latest decision about it, if any.
+	ExplainedCluster struct {
+		ID             string        `json:"id"`
+		Decision       string        `json:"decision,omitempty"`
+		PersonIDs      []int         `json:"personIds"`
+		EmailAddresses []string      `json:"emailAddresses"`
+		Metric         string        `json:"metric"`
+		Threshold      float64       `json:"threshold"`
+		Confidence     float64       `json:"confidence"`
+		Pairs          []ClusterPair `json:"pairs"`
+	}
+	// ClusterPair explains how alike two members of a cluster are. Matched
+	// is set for the pairs the cluster was merged from, including canonically
+	// equal addresses; other pairs are only in the cluster through them.
+	// Distance is the Levenshtein distance between the canonical addresses,
+	// Similarity one less the distance as a fraction of the longer address,
+	// Score the metric's score and Edits the edits turning the first canonical
+	// address into the second. A pair has an ID and a Decision as a cluster
+	// of its two addresses does.
+	ClusterPair struct {
+		ID             string          `json:"id"`
+		Decision       string          `json:"decision,omitempty"`
+		PersonIDs      [2]int          `json:"personIds"`
+		EmailAddresses [2]string       `json:"emailAddresses"`
+		Matched        bool            `json:"matched"`
+		Distance       int             `json:"distance"`
+		Similarity     float64         `json:"similarity"`
+		Score          float64         `json:"score"`
+		Edits          []EditOperation `json:"edits"`
+	}
+)
+
+// explainClusters explains each cluster of positions of people, whose
+// canonical addresses are the indexed strings, given the pairs the clusters
+// were merged from and the decisions made about them.
+func explainClusters(clusters [][]int, pairs []pair, people slapi.People, index *Index, settings Settings, store *decisions.Store) []ExplainedCluster {
+	metric, threshold := settings.metricThreshold()
+	matched := make(map[[2]int]bool, len(pairs))
+	for _, p := range pairs {
+		matched[[2]int{p.a, p.b}] = true
+	}
+	explained := make([]ExplainedCluster, len(clusters))
+	for c, cluster := range clusters {
+		canonical := make([]string, len(cluster))
+		for i, position := range cluster {
+			canonical[i] = index.entries[position]
+		}
+		e := ExplainedCluster{
+			ID:             decisions.ClusterID(canonical),
+			PersonIDs:      make([]int, len(cluster)),
+			EmailAddresses: make([]string, len(cluster)),
+			Metric:         metric.Name(),
+			Threshold:      threshold,
+			Pairs:          []ClusterPair{},
+		}
+		if d, ok := store.Lookup(e.ID); ok {
+			e.Decision = d.Decision
+		}
+		for i, position := range cluster {
+			e.PersonIDs[i] = people[position].ID
+			e.EmailAddresses[i] = people[position].EmailAddress
+		}
+		for i, a := range cluster {
+			for _, b := range cluster[i+1:] {
+				if len(cluster) > maxExplainedPairs && !matched[[2]int{a, b}] {
+					continue
+				}
+				canonicalA, canonicalB := index.entries[a], index.entries[b]
+				distance := ComputeDistance(canonicalA, canonicalB)
+				p := ClusterPair{
+					ID:             decisions.ClusterID([]string{canonicalA, canonicalB}),
+					PersonIDs:      [2]int{people[a].ID, people[b].ID},
+					EmailAddresses: [2]string{people[a].EmailAddress, people[b].EmailAddress},
+					Matched:        matched[[2]int{a, b}] || canonicalA == canonicalB,
+					Distance:       distance,
+					Similarity:     levenshteinSimilarity(canonicalA, canonicalB, distance),
+					Score:          metric.Score(canonicalA, canonicalB),
+					Edits:          ComputeAlignment(canonicalA, canonicalB),
+				}
+				if d, ok := store.Lookup(p.ID); ok {
+					p.Decision = d.Decision
+				}
+				e.Pairs = append(e.Pairs, p)
+			}
+		}
+		for _, p := range e.Pairs {
+			e.Confidence += p.Similarity
+		}
+		if len(e.Pairs) > 0 {
+			e.Confidence /= float64(len(e.Pairs))
+		}
+		explained[c] = e
+	}
+	return explained
+}
+
+// findCluster returns the distinct canonical addresses of the cluster, or of
+// the pair of a cluster's addresses, with the id, as explainClusters
+// identifies them, and whether there is one.
+func findCluster(clusters [][]int, pairs []pair, index *Index, id string) ([]string, bool) {
+	matched := make(map[[2]int]bool, len(pairs))
+	for _, p := range pairs {
+		matched[[2]int{p.a, p.b}] = true
+	}
+	for _, cluster := range clusters {
+		canonical := make([]string, len(cluster))
+		for i, position := range cluster {
+			canonical[i] = index.entries[position]
+		}
+		if decisions.ClusterID(canonical) == id {
+			return canonical, true
+		}
+		for i, a := range cluster {
+			for _, b := range cluster[i+1:] {
+				if len(cluster) > maxExplainedPairs && !matched[[2]int{a, b}] {
+					continue
+				}
+				pairAddresses := []string{index.entries[a], index.entries[b]}
+				if decisions.ClusterID(pairAddresses) == id {
+					return pairAddresses, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// levenshteinSimilarity returns one less distance, the Levenshtein distance
+// between a and b, as a fraction of the longer of them, or 1 when both are
+// empty.
+func levenshteinSimilarity(a, b string, distance int) float64 {
+	longest := max(utf8.RuneCountInString(a), utf8.RuneCountInString(b))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(distance)/float64(longest)
+}
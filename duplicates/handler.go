@@ -1,10 +1,21 @@
 package duplicates
 
 import (
+	"context"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/go-chi/chi"
 	"github.com/go-chi/render"
+	"github.com/slpeople/decisions"
+	"github.com/slpeople/emails"
 	errors "github.com/slpeople/errors"
+	"github.com/slpeople/nicknames"
 	slapi "github.com/slpeople/salesloftapi"
 )
 
@@ -14,33 +25,486 @@ import (
 //  >> https://github.com/agnivade/levenshtein/blob/master/levenshtein.go
 //  >> https://gist.github.com/andrei-m/982927#gistcomment-1931258
 type (
+	// PossibleDuplicatesResponse lists the email addresses that are certainly
+	// duplicates, as they reach the same mailbox once canonicalised, apart from
+	// the clusters of addresses that are possibly duplicates. Pairs lists the
+	// matches the clusters were merged from, Clusters explains and scores
+	// each cluster, and Settings are the settings they were found with.
 	PossibleDuplicatesResponse struct {
 		*PossibleDuplicates `json:"possibleDuplicates"`
+		CertainDuplicates   PossibleDuplicates `json:"certainDuplicates"`
+		Pairs               []DuplicatePair    `json:"pairs"`
+		Clusters            []ExplainedCluster `json:"clusters"`
+		Settings            Settings           `json:"settings"`
+	}
+	// DuplicatePair is two email addresses that are possible duplicates,
+	// classified as a LocalTypo, DomainTypo or BothTypos, with the scores of
+	// their local parts and of their domains.
+	DuplicatePair struct {
+		EmailAddresses [2]string `json:"emailAddresses"`
+		Classification string    `json:"classification"`
+		LocalScore     float64   `json:"localScore"`
+		DomainScore    float64   `json:"domainScore"`
+	}
+	// EmailMatch is a person whose email address matches the one checked.
+	// Distance is the Levenshtein distance between the canonical forms of the
+	// addresses and Score their score under the metric used. Near matches are
+	// classified by the parts of the address that differ.
+	EmailMatch struct {
+		ID             int     `json:"id"`
+		DisplayName    string  `json:"displayName"`
+		EmailAddress   string  `json:"emailAddress"`
+		Distance       int     `json:"distance"`
+		Score          float64 `json:"score"`
+		Classification string  `json:"classification,omitempty"`
+	}
+	EmailCheckResponse struct {
+		EmailAddress   string       `json:"email"`
+		CanonicalEmail string       `json:"canonicalEmail"`
+		Metric         string       `json:"metric"`
+		Threshold      float64      `json:"threshold"`
+		ExactMatches   []EmailMatch `json:"exactMatches"`
+		NearMatches    []EmailMatch `json:"nearMatches"`
+	}
+	// DecisionRequest is a review decision about a cluster or pair of possible
+	// duplicate email addresses: decisions.Confirmed, decisions.NotDuplicate
+	// or decisions.Ignored.
+	DecisionRequest struct {
+		Decision string `json:"decision"`
+	}
+	// DecisionResponse is the decision recorded, with the canonical email
+	// addresses it was made about.
+	DecisionResponse struct {
+		decisions.Decision
+	}
+	// PersonDuplicatesResponse lists the clusters of people that are possibly
+	// the same person, with the weights and threshold they were matched with.
+	PersonDuplicatesResponse struct {
+		Weights   PersonWeights   `json:"weights"`
+		Threshold float64         `json:"threshold"`
+		Clusters  []PersonCluster `json:"clusters"`
+	}
+	Handler struct {
+		source   slapi.PeopleSource
+		settings Settings
+
+		personWeights   PersonWeights
+		personThreshold float64
+		nicknames       *nicknames.Dictionary
+		decisions       *decisions.Store
+
+		mu        sync.Mutex
+		indexed   *slapi.People
+		canonical []string
+		indexes   map[int]*Index
 	}
 )
 
-func PossibleDuplicateEmailsHandler(w http.ResponseWriter, r *http.Request) {
-	people, err := slapi.ListPeople()
+// NewHandler creates a Handler that searches the people provided by source
+// for possible duplicates using settings, unless a request overrides them.
+// The settings must be valid (see Settings.Validate). Review decisions are
+// kept in memory until SetDecisions sets a store for them.
+func NewHandler(source slapi.PeopleSource, settings Settings) *Handler {
+	return &Handler{
+		source:          source,
+		settings:        settings,
+		personWeights:   DefaultPersonWeights,
+		personThreshold: DefaultPersonThreshold,
+		nicknames:       nicknames.Default(),
+		decisions:       decisions.NewStore(),
+	}
+}
+
+// SetPersonMatching sets the weights and threshold people are matched with,
+// in place of DefaultPersonWeights and DefaultPersonThreshold.
+func (h *Handler) SetPersonMatching(weights PersonWeights, threshold float64) {
+	h.personWeights = weights
+	h.personThreshold = threshold
+}
+
+// SetNicknames sets the dictionary of nicknames people's first names are
+// compared with, in place of the bundled one.
+func (h *Handler) SetNicknames(dictionary *nicknames.Dictionary) {
+	h.nicknames = dictionary
+}
+
+// SetDecisions sets the store review decisions are recorded in and read
+// from.
+func (h *Handler) SetDecisions(store *decisions.Store) {
+	h.decisions = store
+}
+
+// PossibleDuplicateEmailsHandler lists the clusters of people's email
+// addresses that are possibly duplicates, and those that are certainly. The
+// query parameters of requestSettings override the handler's settings, and
+// the settings applied are echoed in the response. Pairs of addresses
+// decided not to be duplicates are not matched.
+func (h *Handler) PossibleDuplicateEmailsHandler(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.requestSettings(r)
+	if err != nil {
+		render.Render(w, r, errors.ErrInvalidRequest(err))
+		return
+	}
+	people, index, age, err := h.snapshotIndex(r.Context(), settings)
 	if err != nil {
 		render.Render(w, r, ErrDuplicates(err))
 		return
 	}
+	slapi.SetSnapshotAge(w, age)
 	emailAddresses := make([]string, len(*people))
 	for i := range *people {
 		emailAddresses[i] = (*people)[i].EmailAddress
 	}
-	// TODO: Make this configurable at startup.
-	settings := thresholdSettings{
-		distanceThreshold: 1,
-		lengthThreshold:   1,
+	start := time.Now()
+	clusters, pairs, err := h.possibleDuplicates(r.Context(), index, settings)
+	if err != nil {
+		render.Render(w, r, ErrDuplicates(err))
+		return
+	}
+	duplicateEmailAddresses := positionsToStrings(clusters, emailAddresses)
+	detectionDuration.Observe(time.Since(start).Seconds())
+	response := NewPossibleDuplicatesResponse(&duplicateEmailAddresses)
+	response.CertainDuplicates = positionsToStrings(certainDuplicates(index), emailAddresses)
+	response.Clusters = explainClusters(clusters, pairs, *people, index, settings, h.decisions)
+	response.Settings = settings
+	response.Pairs = make([]DuplicatePair, len(pairs))
+	for i, p := range pairs {
+		response.Pairs[i] = DuplicatePair{
+			EmailAddresses: [2]string{emailAddresses[p.a], emailAddresses[p.b]},
+			Classification: classify(index.entries[p.a], index.entries[p.b]),
+			LocalScore:     p.localScore,
+			DomainScore:    p.domainScore,
+		}
+	}
+	if err := render.Render(w, r, response); err != nil {
+		render.Render(w, r, errors.ErrRender(err))
+		return
+	}
+}
+
+// DecisionHandler records the decision in the request body about the
+// cluster, or pair, of possible duplicate email addresses whose id is the
+// clusterId URL parameter. A cluster not decided about before is looked for
+// among those PossibleDuplicateEmailsHandler lists for the same query
+// parameters. Once a cluster is decided not to be duplicates, none of its
+// pairs of addresses are matched again.
+func (h *Handler) DecisionHandler(w http.ResponseWriter, r *http.Request) {
+	request := &DecisionRequest{}
+	if err := render.Bind(r, request); err != nil {
+		render.Render(w, r, errors.ErrInvalidRequest(err))
+		return
+	}
+	clusterID := chi.URLParam(r, "clusterId")
+	var canonical []string
+	if d, ok := h.decisions.Lookup(clusterID); ok {
+		canonical = d.EmailAddresses
+	} else {
+		settings, err := h.requestSettings(r)
+		if err != nil {
+			render.Render(w, r, errors.ErrInvalidRequest(err))
+			return
+		}
+		_, index, age, err := h.snapshotIndex(r.Context(), settings)
+		if err != nil {
+			render.Render(w, r, ErrDuplicates(err))
+			return
+		}
+		slapi.SetSnapshotAge(w, age)
+		clusters, pairs, err := h.possibleDuplicates(r.Context(), index, settings)
+		if err != nil {
+			render.Render(w, r, ErrDuplicates(err))
+			return
+		}
+		if canonical, ok = findCluster(clusters, pairs, index, clusterID); !ok {
+			render.Render(w, r, errors.ErrNotFound(fmt.Errorf("no cluster of possible duplicates has the id %q", clusterID)))
+			return
+		}
+	}
+	d, err := h.decisions.Record(request.Decision, canonical)
+	if err != nil {
+		render.Render(w, r, ErrDecision(err))
+		return
+	}
+	if err := render.Render(w, r, &DecisionResponse{Decision: d}); err != nil {
+		render.Render(w, r, errors.ErrRender(err))
+		return
+	}
+}
+
+// PossibleDuplicatePeopleHandler lists clusters of people that are possibly
+// the same person, judged by their email addresses, names, how their names
+// sound and titles together. The threshold, emailWeight, nameWeight,
+// phoneticWeight and titleWeight query parameters override the configured
+// matching.
+func (h *Handler) PossibleDuplicatePeopleHandler(w http.ResponseWriter, r *http.Request) {
+	weights, threshold := h.personWeights, h.personThreshold
+	query := r.URL.Query()
+	for _, param := range []struct {
+		name  string
+		value *float64
+	}{{"threshold", &threshold}, {"emailWeight", &weights.Email}, {"nameWeight", &weights.Name}, {"phoneticWeight", &weights.Phonetic}, {"titleWeight", &weights.Title}} {
+		if value := query.Get(param.name); value != "" {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				render.Render(w, r, errors.ErrInvalidRequest(fmt.Errorf("invalid %s %q", param.name, value)))
+				return
+			}
+			*param.value = parsed
+		}
+	}
+	if err := ValidatePersonMatching(weights, threshold); err != nil {
+		render.Render(w, r, errors.ErrInvalidRequest(err))
+		return
+	}
+	people, age, err := h.source.Snapshot(r.Context())
+	if err != nil {
+		render.Render(w, r, ErrDuplicates(err))
+		return
+	}
+	slapi.SetSnapshotAge(w, age)
+	matcher := NewPersonMatcher(weights, threshold)
+	matcher.SetNicknames(h.nicknames)
+	matcher.SetEmailDistance(h.settings.DistanceThreshold)
+	clusters, err := matcher.Clusters(r.Context(), *people)
+	if err != nil {
+		render.Render(w, r, ErrDuplicates(err))
+		return
+	}
+	response := &PersonDuplicatesResponse{
+		Weights:   weights,
+		Threshold: threshold,
+		Clusters:  clusters,
+	}
+	if err := render.Render(w, r, response); err != nil {
+		render.Render(w, r, errors.ErrRender(err))
+		return
+	}
+}
+
+// CheckEmailHandler reports the people whose email address is the same as,
+// or within the distance and length thresholds of, the email query
+// parameter, so that a new person can be checked before being created. The
+// query parameters of requestSettings apply. Addresses are compared in
+// their canonical form, so an exact match may differ from the query in case,
+// dots or a subaddress where the provider ignores them.
+func (h *Handler) CheckEmailHandler(w http.ResponseWriter, r *http.Request) {
+	emailAddress := strings.TrimSpace(r.URL.Query().Get("email"))
+	if emailAddress == "" {
+		render.Render(w, r, errors.ErrInvalidRequest(errMissingEmail))
+		return
+	}
+	settings, err := h.requestSettings(r)
+	if err != nil {
+		render.Render(w, r, errors.ErrInvalidRequest(err))
+		return
+	}
+	people, index, age, err := h.snapshotIndex(r.Context(), settings)
+	if err != nil {
+		render.Render(w, r, ErrDuplicates(err))
+		return
+	}
+	slapi.SetSnapshotAge(w, age)
+	metric, threshold := settings.metricThreshold()
+	response := &EmailCheckResponse{
+		EmailAddress:   emailAddress,
+		CanonicalEmail: emails.Canonicalize(emailAddress),
+		Metric:         metric.Name(),
+		Threshold:      threshold,
+		ExactMatches:   []EmailMatch{},
+		NearMatches:    []EmailMatch{},
+	}
+	matches, err := index.SearchMetric(r.Context(), response.CanonicalEmail, metric, threshold)
+	if err != nil {
+		render.Render(w, r, ErrDuplicates(err))
+		return
 	}
-	duplicateEmailAddresses := FindPossibleDuplicates(emailAddresses, settings)
-	if err := render.Render(w, r, NewPossibleDuplicatesResponse(&duplicateEmailAddresses)); err != nil {
+	for _, match := range matches {
+		if !compareLengths(response.CanonicalEmail, match.Value, settings.LengthThreshold) {
+			continue
+		}
+		local, domain := scoreParts(metric, response.CanonicalEmail, match.Value)
+		if !settings.withinPartThresholds(local, domain) {
+			continue
+		}
+		person := (*people)[match.Position]
+		emailMatch := EmailMatch{
+			ID:           person.ID,
+			DisplayName:  person.DisplayName,
+			EmailAddress: person.EmailAddress,
+			Distance:     match.Distance,
+			Score:        match.Score,
+		}
+		if match.Distance == 0 {
+			response.ExactMatches = append(response.ExactMatches, emailMatch)
+		} else {
+			emailMatch.Classification = classify(response.CanonicalEmail, match.Value)
+			response.NearMatches = append(response.NearMatches, emailMatch)
+		}
+	}
+	if err := render.Render(w, r, response); err != nil {
 		render.Render(w, r, errors.ErrRender(err))
 		return
 	}
 }
 
+// requestSettings applies the distance, length, minClusterSize, metric,
+// threshold, localThreshold and domainThreshold query parameters, if given,
+// to the handler's settings, and validates the result. A threshold given for
+// a distance metric replaces the distance threshold, and must be a whole
+// number unless the metric's scores need not be. Selecting a metric unsets
+// the configured part thresholds, as they may be in other units.
+func (h *Handler) requestSettings(r *http.Request) (Settings, error) {
+	settings := h.settings
+	query := r.URL.Query()
+	for _, param := range []struct {
+		name  string
+		value *int
+	}{{"distance", &settings.DistanceThreshold}, {"length", &settings.LengthThreshold}, {"minClusterSize", &settings.MinClusterSize}} {
+		if value := query.Get(param.name); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return settings, fmt.Errorf("invalid %s %q", param.name, value)
+			}
+			*param.value = parsed
+		}
+	}
+	if name := query.Get("metric"); name != "" {
+		metric, err := LookupMetric(name)
+		if err != nil {
+			return settings, err
+		}
+		settings.Metric = metric
+		settings.SimilarityThreshold, settings.Threshold = 0, nil
+		settings.LocalThreshold, settings.DomainThreshold = nil, nil
+	}
+	metric, _ := settings.metricThreshold()
+	for _, part := range []struct {
+		name      string
+		threshold **float64
+	}{{"localThreshold", &settings.LocalThreshold}, {"domainThreshold", &settings.DomainThreshold}} {
+		if value := query.Get(part.name); value != "" {
+			threshold, err := parseThreshold(metric, part.name, value)
+			if err != nil {
+				return settings, err
+			}
+			*part.threshold = &threshold
+		}
+	}
+	if value := query.Get("threshold"); value != "" {
+		threshold, err := parseThreshold(metric, "threshold", value)
+		if err != nil {
+			return settings, err
+		}
+		_, fractional := metric.(fractionalDistance)
+		switch {
+		case metric.Similarity():
+			settings.SimilarityThreshold = threshold
+		case fractional:
+			settings.Threshold = &threshold
+		case threshold != math.Trunc(threshold):
+			return settings, fmt.Errorf("the %s threshold must be a whole number, got %v", metric.Name(), threshold)
+		default:
+			settings.DistanceThreshold = int(threshold)
+		}
+	}
+	return settings, settings.Validate()
+}
+
+// parseThreshold parses the value of the named query parameter as a
+// threshold for metric.
+func parseThreshold(metric Metric, name, value string) (float64, error) {
+	threshold, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	if err := ValidateThreshold(metric, threshold); err != nil {
+		return 0, err
+	}
+	return threshold, nil
+}
+
+// snapshotIndex returns the source's current people together with an Index of
+// their canonical email addresses suited to settings, in which each address
+// is at the position of its person. Indexes are built once per snapshot and
+// distance, and reused until the source returns a different snapshot.
+func (h *Handler) snapshotIndex(ctx context.Context, settings Settings) (*slapi.People, *Index, time.Duration, error) {
+	people, age, err := h.source.Snapshot(ctx)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	distance, ok := settings.maxEdits()
+	if !ok || distance > maxIndexDistance {
+		// The index will not be searched, so any will do.
+		distance, _ = h.settings.maxEdits()
+	}
+	if distance > maxIndexDistance {
+		distance = maxIndexDistance
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.indexed != people {
+		h.indexes = map[int]*Index{}
+		h.indexed = people
+		h.canonical = make([]string, len(*people))
+		for i := range *people {
+			h.canonical[i] = emails.Canonicalize((*people)[i].EmailAddress)
+		}
+	}
+	index, ok := h.indexes[distance]
+	if !ok {
+		index = NewIndex(h.canonical, distance)
+		h.indexes[distance] = index
+	}
+	return people, index, age, nil
+}
+
+// possibleDuplicates returns the clusters of positions of the indexed
+// strings that are possibly duplicates under settings, and the pairs they
+// were merged from, leaving out the pairs decided not to be duplicates. It
+// stops with ctx's error if ctx is done first.
+func (h *Handler) possibleDuplicates(ctx context.Context, index *Index, settings Settings) ([][]int, []pair, error) {
+	// The index holds canonical addresses, so a cluster whose addresses are
+	// all canonically equal holds certain duplicates only.
+	var clusters [][]int
+	allClusters, pairs, err := index.clusters(ctx, settings, h.decisions.NotDuplicate)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, cluster := range allClusters {
+		if !canonicallyEqual(index, cluster) {
+			clusters = append(clusters, cluster)
+		}
+	}
+	return clusters, pairs, nil
+}
+
+// certainDuplicates returns the positions of the indexed strings that are
+// equal, grouped as disjointSet.sets groups them.
+func certainDuplicates(index *Index) [][]int {
+	first := make(map[string]int)
+	equal := newDisjointSet(index.Len())
+	for _, position := range index.nonEmpty() {
+		s := index.entries[position]
+		if i, ok := first[s]; ok {
+			equal.union(i, position)
+		} else {
+			first[s] = position
+		}
+	}
+	return equal.sets()
+}
+
+// canonicallyEqual reports whether the indexed strings at positions are all
+// equal.
+func canonicallyEqual(index *Index, positions []int) bool {
+	for _, position := range positions[1:] {
+		if index.entries[position] != index.entries[positions[0]] {
+			return false
+		}
+	}
+	return true
+}
+
 func NewPossibleDuplicatesResponse(pdupes *PossibleDuplicates) *PossibleDuplicatesResponse {
 	return &PossibleDuplicatesResponse{PossibleDuplicates: pdupes}
 }
@@ -48,3 +512,19 @@ func NewPossibleDuplicatesResponse(pdupes *PossibleDuplicates) *PossibleDuplicat
 func (pd *PossibleDuplicatesResponse) Render(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
+
+func (dr *DecisionRequest) Bind(r *http.Request) error {
+	return decisions.Validate(dr.Decision)
+}
+
+func (dr *DecisionResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func (pd *PersonDuplicatesResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
+
+func (ec *EmailCheckResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	return nil
+}
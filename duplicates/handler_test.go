@@ -0,0 +1,612 @@
+package duplicates
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/google/go-cmp/cmp"
+	"github.com/slpeople/decisions"
+	slapi "github.com/slpeople/salesloftapi"
+)
+
+func TestPossibleDuplicateEmailsHandler(t *testing.T) {
+	source := slapi.PeopleSourceFunc(func(ctx context.Context) (*slapi.People, time.Duration, error) {
+		return &slapi.People{
+			{EmailAddress: "dan@test.com"},
+			{EmailAddress: "dann@test.com"},
+			{EmailAddress: "dave@testing.com"},
+			{EmailAddress: "John.Doe+crm@gmail.com"},
+			{EmailAddress: "johndoe@googlemail.com"},
+			{EmailAddress: "Dave@Testing.com"},
+		}, 0, nil
+	})
+	w := httptest.NewRecorder()
+	NewHandler(source, DefaultSettings).PossibleDuplicateEmailsHandler(w, httptest.NewRequest("GET", "/people/emails/duplicates", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d\n", w.Code)
+	}
+	var result struct {
+		PossibleDuplicates PossibleDuplicates `json:"possibleDuplicates"`
+		CertainDuplicates  PossibleDuplicates `json:"certainDuplicates"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("unable to decode the response: %v\n", err)
+	}
+	expected := PossibleDuplicates{{"dan@test.com", "dann@test.com"}}
+	if !cmp.Equal(result.PossibleDuplicates, expected) {
+		t.Fatalf("unexpected duplicates: \n\tresult: %#v\n\texpect: %#v\n", result.PossibleDuplicates, expected)
+	}
+	expected = PossibleDuplicates{{"dave@testing.com", "Dave@Testing.com"}, {"John.Doe+crm@gmail.com", "johndoe@googlemail.com"}}
+	if !cmp.Equal(result.CertainDuplicates, expected) {
+		t.Fatalf("unexpected certain duplicates: \n\tresult: %#v\n\texpect: %#v\n", result.CertainDuplicates, expected)
+	}
+}
+
+func TestCheckEmailHandler(t *testing.T) {
+	source := slapi.PeopleSourceFunc(func(ctx context.Context) (*slapi.People, time.Duration, error) {
+		return &slapi.People{
+			{ID: 1, DisplayName: "Dan Test", EmailAddress: "dan@test.com"},
+			{ID: 2, DisplayName: "Dann Test", EmailAddress: "dann@test.com"},
+			{ID: 3, DisplayName: "Dave Testing", EmailAddress: "dave@testing.com"},
+			{ID: 4, DisplayName: "Daniel Test", EmailAddress: "dan@test.com"},
+			{ID: 5, DisplayName: "John Doe", EmailAddress: "John.Doe+crm@gmail.com"},
+		}, 0, nil
+	})
+	checkTestData := []struct {
+		email    string
+		query    string
+		code     int
+		expected EmailCheckResponse
+	}{
+		{
+			email: "dan@test.com",
+			code:  http.StatusOK,
+			expected: EmailCheckResponse{
+				EmailAddress:   "dan@test.com",
+				CanonicalEmail: "dan@test.com",
+				Metric:         Levenshtein,
+				Threshold:      1,
+				ExactMatches: []EmailMatch{
+					{ID: 1, DisplayName: "Dan Test", EmailAddress: "dan@test.com", Distance: 0},
+					{ID: 4, DisplayName: "Daniel Test", EmailAddress: "dan@test.com", Distance: 0},
+				},
+				NearMatches: []EmailMatch{
+					{ID: 2, DisplayName: "Dann Test", EmailAddress: "dann@test.com", Distance: 1, Score: 1, Classification: LocalTypo},
+				},
+			},
+		},
+		// As in batch detection, addresses whose lengths differ by more than
+		// the length threshold do not match.
+		{
+			email: "dan@test.com",
+			query: "&length=0",
+			code:  http.StatusOK,
+			expected: EmailCheckResponse{
+				EmailAddress:   "dan@test.com",
+				CanonicalEmail: "dan@test.com",
+				Metric:         Levenshtein,
+				Threshold:      1,
+				ExactMatches: []EmailMatch{
+					{ID: 1, DisplayName: "Dan Test", EmailAddress: "dan@test.com", Distance: 0},
+					{ID: 4, DisplayName: "Daniel Test", EmailAddress: "dan@test.com", Distance: 0},
+				},
+				NearMatches: []EmailMatch{},
+			},
+		},
+		{
+			email: " dave@testin.com ",
+			code:  http.StatusOK,
+			expected: EmailCheckResponse{
+				EmailAddress:   "dave@testin.com",
+				CanonicalEmail: "dave@testin.com",
+				Metric:         Levenshtein,
+				Threshold:      1,
+				ExactMatches:   []EmailMatch{},
+				NearMatches: []EmailMatch{
+					{ID: 3, DisplayName: "Dave Testing", EmailAddress: "dave@testing.com", Distance: 1, Score: 1, Classification: DomainTypo},
+				},
+			},
+		},
+		{
+			email:    "someone@else.com",
+			code:     http.StatusOK,
+			expected: EmailCheckResponse{EmailAddress: "someone@else.com", CanonicalEmail: "someone@else.com", Metric: Levenshtein, Threshold: 1, ExactMatches: []EmailMatch{}, NearMatches: []EmailMatch{}},
+		},
+		{
+			email: "johndoe@googlemail.com",
+			code:  http.StatusOK,
+			expected: EmailCheckResponse{
+				EmailAddress:   "johndoe@googlemail.com",
+				CanonicalEmail: "johndoe@gmail.com",
+				Metric:         Levenshtein,
+				Threshold:      1,
+				ExactMatches: []EmailMatch{
+					{ID: 5, DisplayName: "John Doe", EmailAddress: "John.Doe+crm@gmail.com", Distance: 0},
+				},
+				NearMatches: []EmailMatch{},
+			},
+		},
+		{email: "", code: http.StatusBadRequest},
+		{email: "dan@test.com", query: "&length=-1", code: http.StatusBadRequest},
+	}
+	h := NewHandler(source, DefaultSettings)
+	for _, td := range checkTestData {
+		url := "/people/emails/duplicates/check?email=" + neturl.QueryEscape(td.email) + td.query
+		w := httptest.NewRecorder()
+		h.CheckEmailHandler(w, httptest.NewRequest("GET", url, nil))
+		if w.Code != td.code {
+			t.Fatalf("%q: unexpected status: %d\n", td.email, w.Code)
+		}
+		if td.code != http.StatusOK {
+			continue
+		}
+		var result EmailCheckResponse
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatalf("%q: unable to decode the response: %v\n", td.email, err)
+		}
+		if !cmp.Equal(result, td.expected) {
+			t.Fatalf("%q: unexpected matches: \n\tresult: %#v\n\texpect: %#v\n", td.email, result, td.expected)
+		}
+	}
+}
+
+func TestPossibleDuplicateEmailsHandlerMetrics(t *testing.T) {
+	source := slapi.PeopleSourceFunc(func(ctx context.Context) (*slapi.People, time.Duration, error) {
+		return &slapi.People{
+			{EmailAddress: "dan@test.com"},
+			{EmailAddress: "dna@test.com"},
+			{EmailAddress: "martha@test.com"},
+			{EmailAddress: "marhta@test.com"},
+		}, 0, nil
+	})
+	metricTestData := []struct {
+		query    string
+		code     int
+		expected PossibleDuplicates
+	}{
+		{query: "", code: http.StatusOK, expected: PossibleDuplicates{}},
+		{query: "?threshold=2", code: http.StatusOK, expected: PossibleDuplicates{{"dan@test.com", "dna@test.com"}, {"martha@test.com", "marhta@test.com"}}},
+		{query: "?metric=damerau", code: http.StatusOK, expected: PossibleDuplicates{{"dan@test.com", "dna@test.com"}, {"martha@test.com", "marhta@test.com"}}},
+		{query: "?metric=hamming&threshold=2", code: http.StatusOK, expected: PossibleDuplicates{{"dan@test.com", "dna@test.com"}, {"martha@test.com", "marhta@test.com"}}},
+		{query: "?metric=jarowinkler&threshold=1", code: http.StatusOK, expected: PossibleDuplicates{}},
+		{query: "?metric=JaroWinkler", code: http.StatusOK, expected: PossibleDuplicates{{"dan@test.com", "dna@test.com"}, {"martha@test.com", "marhta@test.com"}}},
+		{query: "?metric=jaccard&threshold=0.5", code: http.StatusOK, expected: PossibleDuplicates{{"dan@test.com", "dna@test.com"}, {"martha@test.com", "marhta@test.com"}}},
+		{query: "?metric=soundex", code: http.StatusBadRequest},
+		{query: "?metric=jarowinkler&threshold=1.5", code: http.StatusBadRequest},
+		{query: "?threshold=1.5", code: http.StatusBadRequest},
+		{query: "?threshold=-1", code: http.StatusBadRequest},
+		{query: "?threshold=close", code: http.StatusBadRequest},
+		// Thresholds allowing more edits than an index is built for compare
+		// every pair of addresses instead.
+		{query: "?distance=3", code: http.StatusOK, expected: PossibleDuplicates{{"dan@test.com", "dna@test.com"}, {"martha@test.com", "marhta@test.com"}}},
+		{query: "?metric=damerau&threshold=2", code: http.StatusOK, expected: PossibleDuplicates{{"dan@test.com", "dna@test.com"}, {"martha@test.com", "marhta@test.com"}}},
+		{query: "?metric=keyboard&threshold=2.5", code: http.StatusOK, expected: PossibleDuplicates{{"dan@test.com", "dna@test.com"}, {"martha@test.com", "marhta@test.com"}}},
+	}
+	h := NewHandler(source, Settings{DistanceThreshold: 1, MinClusterSize: 2})
+	for _, td := range metricTestData {
+		w := httptest.NewRecorder()
+		h.PossibleDuplicateEmailsHandler(w, httptest.NewRequest("GET", "/people/emails/duplicates"+td.query, nil))
+		if w.Code != td.code {
+			t.Fatalf("%q: unexpected status: %d\n", td.query, w.Code)
+		}
+		if td.code != http.StatusOK {
+			continue
+		}
+		var result struct {
+			PossibleDuplicates PossibleDuplicates `json:"possibleDuplicates"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatalf("%q: unable to decode the response: %v\n", td.query, err)
+		}
+		if !cmp.Equal(result.PossibleDuplicates, td.expected) {
+			t.Fatalf("%q: unexpected duplicates: \n\tresult: %#v\n\texpect: %#v\n", td.query, result.PossibleDuplicates, td.expected)
+		}
+	}
+}
+
+// Keyboard distances count adjacent-key slips as half an edit, so their
+// thresholds need not be whole numbers.
+func TestPossibleDuplicateEmailsHandlerKeyboardThreshold(t *testing.T) {
+	source := slapi.PeopleSourceFunc(func(ctx context.Context) (*slapi.People, time.Duration, error) {
+		return &slapi.People{
+			{EmailAddress: "john@test.com"},
+			{EmailAddress: "jphn@test.com"},
+			{EmailAddress: "jxhn@test.com"},
+		}, 0, nil
+	})
+	keyboardTestData := []struct {
+		query     string
+		threshold float64
+		expected  PossibleDuplicates
+	}{
+		{query: "?metric=keyboard&threshold=0.5", threshold: 0.5, expected: PossibleDuplicates{{"john@test.com", "jphn@test.com"}}},
+		{query: "?metric=keyboard&threshold=1", threshold: 1, expected: PossibleDuplicates{{"john@test.com", "jphn@test.com", "jxhn@test.com"}}},
+		{query: "?metric=keyboard", threshold: 1, expected: PossibleDuplicates{{"john@test.com", "jphn@test.com", "jxhn@test.com"}}},
+	}
+	h := NewHandler(source, Settings{DistanceThreshold: 1, MinClusterSize: 2})
+	for _, td := range keyboardTestData {
+		w := httptest.NewRecorder()
+		h.PossibleDuplicateEmailsHandler(w, httptest.NewRequest("GET", "/people/emails/duplicates"+td.query, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%q: unexpected status: %d\n", td.query, w.Code)
+		}
+		var result struct {
+			PossibleDuplicates PossibleDuplicates `json:"possibleDuplicates"`
+			Settings           struct {
+				Threshold float64 `json:"threshold"`
+			} `json:"settings"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatalf("%q: unable to decode the response: %v\n", td.query, err)
+		}
+		if result.Settings.Threshold != td.threshold {
+			t.Fatalf("%q: unexpected threshold: \n\tresult: %v\n\texpect: %v\n", td.query, result.Settings.Threshold, td.threshold)
+		}
+		if !cmp.Equal(result.PossibleDuplicates, td.expected) {
+			t.Fatalf("%q: unexpected duplicates: \n\tresult: %#v\n\texpect: %#v\n", td.query, result.PossibleDuplicates, td.expected)
+		}
+	}
+}
+
+func TestPossibleDuplicateEmailsHandlerSettings(t *testing.T) {
+	source := slapi.PeopleSourceFunc(func(ctx context.Context) (*slapi.People, time.Duration, error) {
+		return &slapi.People{
+			{EmailAddress: "dan@test.com"},
+			{EmailAddress: "dann@test.com"},
+			{EmailAddress: "danny@test.com"},
+			{EmailAddress: "martha@test.com"},
+			{EmailAddress: "marhta@test.com"},
+		}, 0, nil
+	})
+	type echoedSettings struct {
+		Distance       int     `json:"distance"`
+		Length         int     `json:"length"`
+		MinClusterSize int     `json:"minClusterSize"`
+		Metric         string  `json:"metric"`
+		Threshold      float64 `json:"threshold"`
+	}
+	settingsTestData := []struct {
+		query    string
+		code     int
+		expected PossibleDuplicates
+		settings echoedSettings
+	}{
+		{
+			query:    "",
+			code:     http.StatusOK,
+			expected: PossibleDuplicates{{"dan@test.com", "dann@test.com", "danny@test.com"}},
+			settings: echoedSettings{Distance: 1, Length: 1, MinClusterSize: 2, Metric: Levenshtein, Threshold: 1},
+		},
+		{
+			query:    "?distance=2",
+			code:     http.StatusOK,
+			expected: PossibleDuplicates{{"dan@test.com", "dann@test.com", "danny@test.com"}, {"martha@test.com", "marhta@test.com"}},
+			settings: echoedSettings{Distance: 2, Length: 1, MinClusterSize: 2, Metric: Levenshtein, Threshold: 2},
+		},
+		{
+			query:    "?distance=2&minClusterSize=3",
+			code:     http.StatusOK,
+			expected: PossibleDuplicates{{"dan@test.com", "dann@test.com", "danny@test.com"}},
+			settings: echoedSettings{Distance: 2, Length: 1, MinClusterSize: 3, Metric: Levenshtein, Threshold: 2},
+		},
+		{
+			query:    "?length=0",
+			code:     http.StatusOK,
+			expected: PossibleDuplicates{},
+			settings: echoedSettings{Distance: 1, Length: 0, MinClusterSize: 2, Metric: Levenshtein, Threshold: 1},
+		},
+		{query: "?distance=-1", code: http.StatusBadRequest},
+		{query: "?distance=1.5", code: http.StatusBadRequest},
+		{query: "?length=long", code: http.StatusBadRequest},
+		{query: "?minClusterSize=1", code: http.StatusBadRequest},
+	}
+	h := NewHandler(source, DefaultSettings)
+	for _, td := range settingsTestData {
+		w := httptest.NewRecorder()
+		h.PossibleDuplicateEmailsHandler(w, httptest.NewRequest("GET", "/people/emails/duplicates"+td.query, nil))
+		if w.Code != td.code {
+			t.Fatalf("%q: unexpected status: %d\n", td.query, w.Code)
+		}
+		if td.code != http.StatusOK {
+			continue
+		}
+		var result struct {
+			PossibleDuplicates PossibleDuplicates `json:"possibleDuplicates"`
+			Settings           echoedSettings     `json:"settings"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatalf("%q: unable to decode the response: %v\n", td.query, err)
+		}
+		if !cmp.Equal(result.PossibleDuplicates, td.expected) {
+			t.Fatalf("%q: unexpected duplicates: \n\tresult: %#v\n\texpect: %#v\n", td.query, result.PossibleDuplicates, td.expected)
+		}
+		if result.Settings != td.settings {
+			t.Fatalf("%q: unexpected settings: \n\tresult: %+v\n\texpect: %+v\n", td.query, result.Settings, td.settings)
+		}
+	}
+}
+
+func TestPossibleDuplicateEmailsHandlerPairs(t *testing.T) {
+	source := slapi.PeopleSourceFunc(func(ctx context.Context) (*slapi.People, time.Duration, error) {
+		return &slapi.People{
+			{EmailAddress: "dan@test.com"},
+			{EmailAddress: "dan@tesst.com"},
+			{EmailAddress: "dann@test.com"},
+			{EmailAddress: "bob@example.com"},
+			{EmailAddress: "bobb@exanple.com"},
+		}, 0, nil
+	})
+	pairsTestData := []struct {
+		query      string
+		code       int
+		duplicates PossibleDuplicates
+		pairs      []DuplicatePair
+	}{
+		{
+			query:      "?threshold=2",
+			code:       http.StatusOK,
+			duplicates: PossibleDuplicates{{"dan@test.com", "dan@tesst.com", "dann@test.com"}, {"bob@example.com", "bobb@exanple.com"}},
+			pairs: []DuplicatePair{
+				{EmailAddresses: [2]string{"dan@test.com", "dan@tesst.com"}, Classification: DomainTypo, LocalScore: 0, DomainScore: 1},
+				{EmailAddresses: [2]string{"dan@test.com", "dann@test.com"}, Classification: LocalTypo, LocalScore: 1, DomainScore: 0},
+				{EmailAddresses: [2]string{"dan@tesst.com", "dann@test.com"}, Classification: BothTypos, LocalScore: 1, DomainScore: 1},
+				{EmailAddresses: [2]string{"bob@example.com", "bobb@exanple.com"}, Classification: BothTypos, LocalScore: 1, DomainScore: 1},
+			},
+		},
+		{
+			query:      "?threshold=2&localThreshold=0",
+			code:       http.StatusOK,
+			duplicates: PossibleDuplicates{{"dan@test.com", "dan@tesst.com"}},
+			pairs: []DuplicatePair{
+				{EmailAddresses: [2]string{"dan@test.com", "dan@tesst.com"}, Classification: DomainTypo, LocalScore: 0, DomainScore: 1},
+			},
+		},
+		{
+			query:      "?threshold=2&domainThreshold=0",
+			code:       http.StatusOK,
+			duplicates: PossibleDuplicates{{"dan@test.com", "dann@test.com"}},
+			pairs: []DuplicatePair{
+				{EmailAddresses: [2]string{"dan@test.com", "dann@test.com"}, Classification: LocalTypo, LocalScore: 1, DomainScore: 0},
+			},
+		},
+		{query: "?localThreshold=close", code: http.StatusBadRequest},
+		{query: "?domainThreshold=-1", code: http.StatusBadRequest},
+		{query: "?metric=jarowinkler&domainThreshold=2", code: http.StatusBadRequest},
+	}
+	h := NewHandler(source, DefaultSettings)
+	for _, td := range pairsTestData {
+		w := httptest.NewRecorder()
+		h.PossibleDuplicateEmailsHandler(w, httptest.NewRequest("GET", "/people/emails/duplicates"+td.query, nil))
+		if w.Code != td.code {
+			t.Fatalf("%q: unexpected status: %d\n", td.query, w.Code)
+		}
+		if td.code != http.StatusOK {
+			continue
+		}
+		var result struct {
+			PossibleDuplicates PossibleDuplicates `json:"possibleDuplicates"`
+			Pairs              []DuplicatePair    `json:"pairs"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatalf("%q: unable to decode the response: %v\n", td.query, err)
+		}
+		if !cmp.Equal(result.PossibleDuplicates, td.duplicates) {
+			t.Fatalf("%q: unexpected duplicates: \n\tresult: %#v\n\texpect: %#v\n", td.query, result.PossibleDuplicates, td.duplicates)
+		}
+		if !cmp.Equal(result.Pairs, td.pairs) {
+			t.Fatalf("%q: unexpected pairs: \n\tresult: %#v\n\texpect: %#v\n", td.query, result.Pairs, td.pairs)
+		}
+	}
+}
+
+func TestPossibleDuplicatePeopleHandler(t *testing.T) {
+	source := slapi.PeopleSourceFunc(func(ctx context.Context) (*slapi.People, time.Duration, error) {
+		return &personTestPeople, 0, nil
+	})
+	peopleTestData := []struct {
+		query    string
+		code     int
+		expected [][]int
+	}{
+		{query: "", code: http.StatusOK, expected: [][]int{{1, 2}, {3, 4}}},
+		{query: "?emailWeight=0&nameWeight=1&titleWeight=1&threshold=0.95", code: http.StatusOK, expected: [][]int{{3, 4}, {5, 6}}},
+		{query: "?threshold=2", code: http.StatusBadRequest},
+		{query: "?nameWeight=-1", code: http.StatusBadRequest},
+		{query: "?emailWeight=heavy", code: http.StatusBadRequest},
+	}
+	h := NewHandler(source, DefaultSettings)
+	for _, td := range peopleTestData {
+		w := httptest.NewRecorder()
+		h.PossibleDuplicatePeopleHandler(w, httptest.NewRequest("GET", "/people/duplicates"+td.query, nil))
+		if w.Code != td.code {
+			t.Fatalf("%q: unexpected status: %d\n", td.query, w.Code)
+		}
+		if td.code != http.StatusOK {
+			continue
+		}
+		var result PersonDuplicatesResponse
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatalf("%q: unable to decode the response: %v\n", td.query, err)
+		}
+		ids := [][]int{}
+		for _, cluster := range result.Clusters {
+			var clusterIDs []int
+			for _, person := range cluster.People {
+				clusterIDs = append(clusterIDs, person.ID)
+			}
+			ids = append(ids, clusterIDs)
+		}
+		if !cmp.Equal(ids, td.expected) {
+			t.Fatalf("%q: unexpected clusters: \n\tresult: %v\n\texpect: %v\n", td.query, ids, td.expected)
+		}
+	}
+}
+
+func TestPossibleDuplicateEmailsHandlerClusters(t *testing.T) {
+	source := slapi.PeopleSourceFunc(func(ctx context.Context) (*slapi.People, time.Duration, error) {
+		return &slapi.People{
+			{ID: 1, EmailAddress: "dan@test.com"},
+			{ID: 2, EmailAddress: "dann@test.com"},
+			{ID: 3, EmailAddress: "danny@test.com"},
+			{ID: 4, EmailAddress: "Dave@Testing.com"},
+			{ID: 5, EmailAddress: "dave@testing.com"},
+		}, 0, nil
+	})
+	w := httptest.NewRecorder()
+	NewHandler(source, DefaultSettings).PossibleDuplicateEmailsHandler(w, httptest.NewRequest("GET", "/people/emails/duplicates", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d\n", w.Code)
+	}
+	var result struct {
+		Clusters []ExplainedCluster `json:"clusters"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("unable to decode the response: %v\n", err)
+	}
+	// Similarities are worked out at run time, as the handler does, rather
+	// than as exact constants.
+	one, two := 1.0, 2.0
+	similarities := []float64{1 - one/13, 1 - two/14, 1 - one/14}
+	// danny is only in the cluster through dann, so the pair of it and dan,
+	// two edits apart, was not matched and lowers the cluster's confidence.
+	expected := []ExplainedCluster{
+		{
+			ID:             decisions.ClusterID([]string{"dan@test.com", "dann@test.com", "danny@test.com"}),
+			PersonIDs:      []int{1, 2, 3},
+			EmailAddresses: []string{"dan@test.com", "dann@test.com", "danny@test.com"},
+			Metric:         Levenshtein,
+			Threshold:      1,
+			Confidence:     (similarities[0] + similarities[1] + similarities[2]) / 3,
+			Pairs: []ClusterPair{
+				{
+					ID:             decisions.ClusterID([]string{"dan@test.com", "dann@test.com"}),
+					PersonIDs:      [2]int{1, 2},
+					EmailAddresses: [2]string{"dan@test.com", "dann@test.com"},
+					Matched:        true,
+					Distance:       1,
+					Similarity:     similarities[0],
+					Score:          1,
+					Edits:          []EditOperation{{Op: Insert, Position: 2, To: "n"}},
+				},
+				{
+					ID:             decisions.ClusterID([]string{"dan@test.com", "danny@test.com"}),
+					PersonIDs:      [2]int{1, 3},
+					EmailAddresses: [2]string{"dan@test.com", "danny@test.com"},
+					Distance:       2,
+					Similarity:     similarities[1],
+					Score:          2,
+					Edits:          []EditOperation{{Op: Insert, Position: 2, To: "n"}, {Op: Insert, Position: 3, To: "y"}},
+				},
+				{
+					ID:             decisions.ClusterID([]string{"dann@test.com", "danny@test.com"}),
+					PersonIDs:      [2]int{2, 3},
+					EmailAddresses: [2]string{"dann@test.com", "danny@test.com"},
+					Matched:        true,
+					Distance:       1,
+					Similarity:     similarities[2],
+					Score:          1,
+					Edits:          []EditOperation{{Op: Insert, Position: 4, To: "y"}},
+				},
+			},
+		},
+	}
+	if !cmp.Equal(result.Clusters, expected) {
+		t.Fatalf("unexpected clusters: \n\tresult: %+v\n\texpect: %+v\n", result.Clusters, expected)
+	}
+}
+
+func TestDecisionHandler(t *testing.T) {
+	source := slapi.PeopleSourceFunc(func(ctx context.Context) (*slapi.People, time.Duration, error) {
+		return &slapi.People{
+			{ID: 1, EmailAddress: "dan@test.com"},
+			{ID: 2, EmailAddress: "dann@test.com"},
+			{ID: 3, EmailAddress: "danny@test.com"},
+			{ID: 4, EmailAddress: "bob@example.com"},
+			{ID: 5, EmailAddress: "Bobb@Example.com"},
+		}, 0, nil
+	})
+	danPair := decisions.ClusterID([]string{"dan@test.com", "dann@test.com"})
+	bobCluster := decisions.ClusterID([]string{"bob@example.com", "bobb@example.com"})
+	decisionTestData := []struct {
+		clusterID  string
+		body       string
+		code       int
+		addresses  []string
+		duplicates PossibleDuplicates
+		decision   string
+	}{
+		{clusterID: bobCluster, body: `{"decision": "maybe"}`, code: http.StatusBadRequest},
+		{clusterID: bobCluster, body: `not json`, code: http.StatusBadRequest},
+		{clusterID: "0123456789abcdef", body: `{"decision": "confirmed"}`, code: http.StatusNotFound},
+		// Once dan and dann are not duplicates, danny only matches dann.
+		{
+			clusterID:  danPair,
+			body:       `{"decision": "not_duplicate"}`,
+			code:       http.StatusOK,
+			addresses:  []string{"dan@test.com", "dann@test.com"},
+			duplicates: PossibleDuplicates{{"dann@test.com", "danny@test.com"}, {"bob@example.com", "Bobb@Example.com"}},
+		},
+		{
+			clusterID:  bobCluster,
+			body:       `{"decision": "not_duplicate"}`,
+			code:       http.StatusOK,
+			addresses:  []string{"bob@example.com", "bobb@example.com"},
+			duplicates: PossibleDuplicates{{"dann@test.com", "danny@test.com"}},
+		},
+		// A decision may be changed although the cluster is no longer found.
+		{
+			clusterID:  bobCluster,
+			body:       `{"decision": "confirmed"}`,
+			code:       http.StatusOK,
+			addresses:  []string{"bob@example.com", "bobb@example.com"},
+			duplicates: PossibleDuplicates{{"dann@test.com", "danny@test.com"}, {"bob@example.com", "Bobb@Example.com"}},
+			decision:   decisions.Confirmed,
+		},
+	}
+	h := NewHandler(source, DefaultSettings)
+	router := chi.NewRouter()
+	router.Post("/people/duplicates/{clusterId}/decision", h.DecisionHandler)
+	for _, td := range decisionTestData {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "/people/duplicates/"+td.clusterID+"/decision", strings.NewReader(td.body))
+		r.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, r)
+		if w.Code != td.code {
+			t.Fatalf("%s %s: unexpected status: %d\n", td.clusterID, td.body, w.Code)
+		}
+		if td.code != http.StatusOK {
+			continue
+		}
+		var decision decisions.Decision
+		if err := json.NewDecoder(w.Body).Decode(&decision); err != nil {
+			t.Fatalf("%s %s: unable to decode the response: %v\n", td.clusterID, td.body, err)
+		}
+		if decision.ClusterID != td.clusterID || !cmp.Equal(decision.EmailAddresses, td.addresses) {
+			t.Fatalf("%s %s: unexpected decision: \n\tresult: %+v\n\texpect: %v\n", td.clusterID, td.body, decision, td.addresses)
+		}
+
+		w = httptest.NewRecorder()
+		h.PossibleDuplicateEmailsHandler(w, httptest.NewRequest("GET", "/people/emails/duplicates", nil))
+		var result struct {
+			PossibleDuplicates PossibleDuplicates `json:"possibleDuplicates"`
+			Clusters           []ExplainedCluster `json:"clusters"`
+		}
+		if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+			t.Fatalf("%s %s: unable to decode the duplicates: %v\n", td.clusterID, td.body, err)
+		}
+		if !cmp.Equal(result.PossibleDuplicates, td.duplicates) {
+			t.Fatalf("%s %s: unexpected duplicates: \n\tresult: %#v\n\texpect: %#v\n", td.clusterID, td.body, result.PossibleDuplicates, td.duplicates)
+		}
+		if last := result.Clusters[len(result.Clusters)-1]; last.ID == bobCluster && last.Decision != td.decision {
+			t.Fatalf("%s %s: unexpected decision about bob: \n\tresult: %q\n\texpect: %q\n", td.clusterID, td.body, last.Decision, td.decision)
+		}
+	}
+}
@@ -0,0 +1,544 @@
+package duplicates
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+type (
+	// Index finds the strings within a small edit distance of a given string
+	// without comparing it to every indexed string. It is a SymSpell-style
+	// deletion index: every string is stored under each variant obtained by
+	// deleting up to maxDistance of its characters. Two strings within
+	// Levenshtein distance k of each other always share a variant with at most
+	// k deletions from each, so looking up the query's own variants yields
+	// every candidate, and only those candidates have their distance computed.
+	//
+	// The number of variants grows with the length of the strings raised to
+	// the power of maxDistance, so an Index suits distances of one or two.
+	// Similarity metrics find their candidates by the tokens strings share
+	// instead (see tokenIndex), which an Index builds for each metric the
+	// first time it is needed. An Index is safe for concurrent use.
+	Index struct {
+		entries     []string
+		maxDistance int
+		variants    map[uint64][]int
+
+		mu     sync.Mutex
+		tokens map[string]*tokenIndex
+	}
+	// Match is an indexed string found by Index.Search. Position is the
+	// string's position in the slice the index was built from, Distance its
+	// Levenshtein distance from the query and Score its score under the
+	// metric searched with.
+	Match struct {
+		Position int     `json:"-"`
+		Value    string  `json:"value"`
+		Distance int     `json:"distance"`
+		Score    float64 `json:"score"`
+	}
+	// tokenIndex holds the tokens of the indexed strings under an
+	// overlapBounded metric. Tokens are numbered from the rarest among the
+	// indexed strings to the commonest, and each string's are kept in that
+	// order, so that two strings sharing at least m tokens share one among
+	// the first n-m+1 tokens of each, where n is each string's number of
+	// tokens. Only those first tokens are looked up, and the commonest
+	// tokens, which nearly every string has, seldom are.
+	//
+	// Each token lists the strings holding it from the shortest to the
+	// longest, so that those of similar length are found together.
+	tokenIndex struct {
+		metric   overlapBounded
+		ids      map[string]int32
+		tokens   [][]int32
+		lengths  []int
+		byLength []int
+		postings [][]tokenPosting
+	}
+	// tokenPosting is an indexed string holding a token, and the token's rank
+	// among the string's tokens.
+	tokenPosting struct {
+		position, rank int
+	}
+)
+
+// NewIndex indexes strs so that Search can find strings up to maxDistance
+// edits away. Empty strings are not indexed.
+func NewIndex(strs []string, maxDistance int) *Index {
+	if maxDistance < 0 {
+		maxDistance = 0
+	}
+	idx := &Index{
+		entries:     strs,
+		maxDistance: maxDistance,
+		variants:    make(map[uint64][]int),
+		tokens:      make(map[string]*tokenIndex),
+	}
+	var hashes []uint64
+	for i, s := range strs {
+		if s == "" {
+			continue
+		}
+		hashes = deletionVariants([]rune(s), maxDistance, hashes[:0])
+		for _, variant := range hashes {
+			idx.variants[variant] = append(idx.variants[variant], i)
+		}
+	}
+	return idx
+}
+
+// Len returns the number of strings the index was built from.
+func (idx *Index) Len() int {
+	return len(idx.entries)
+}
+
+// MaxDistance returns the largest distance the index can search.
+func (idx *Index) MaxDistance() int {
+	return idx.maxDistance
+}
+
+// Search returns every indexed string within Levenshtein distance k of s,
+// ordered as SearchMetric orders them.
+func (idx *Index) Search(s string, k int) []Match {
+	// The background context is never done.
+	matches, _ := idx.SearchMetric(context.Background(), s, levenshteinMetric{}, float64(k))
+	return matches
+}
+
+// SearchMetric returns every indexed string that is within threshold of s
+// under metric, closest first, then likeliest to be a typo of s, and then in
+// the order they were indexed. Only candidates are scored: strings sharing a
+// deletion variant with s when the metric limits the edit distance to at
+// most the index's maximum distance, and strings sharing enough tokens with s
+// when the metric's threshold requires them to share any. Otherwise every
+// indexed string is scored. The search stops with ctx's error if ctx is done
+// first.
+func (idx *Index) SearchMetric(ctx context.Context, s string, metric Metric, threshold float64) ([]Match, error) {
+	var positions []int
+	if bounded, ok := metric.(editBounded); ok && bounded.maxEdits(threshold) <= idx.maxDistance {
+		positions = idx.candidates(s, bounded.maxEdits(threshold))
+	} else if tokens, ok := idx.tokenIndex(metric, threshold); ok {
+		positions = tokens.candidates(s, threshold)
+	} else {
+		positions = idx.nonEmpty()
+	}
+	matches := []Match{}
+	for _, position := range positions {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		score := metric.Score(s, idx.entries[position])
+		if !withinThreshold(metric, score, threshold) {
+			continue
+		}
+		matches = append(matches, Match{
+			Position: position,
+			Value:    idx.entries[position],
+			Distance: ComputeDistance(s, idx.entries[position]),
+			Score:    score,
+		})
+	}
+	// Equally close matches are ranked by how likely they are to be a typo
+	// of s on a QWERTY keyboard.
+	typoDistances := make(map[int]float64, len(matches))
+	for _, match := range matches {
+		typoDistances[match.Position] = ComputeKeyboardDistance(s, match.Value, QWERTY)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		switch {
+		case matches[i].Score == matches[j].Score:
+			return typoDistances[matches[i].Position] < typoDistances[matches[j].Position]
+		case metric.Similarity():
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].Score < matches[j].Score
+	})
+	return matches, nil
+}
+
+// PossibleDuplicates clusters the indexed strings as FindPossibleDuplicates
+// describes. The candidate pairs are those SearchMetric would score: strings
+// stored under the same variant, or sharing enough tokens, and otherwise
+// every pair of strings.
+func (idx *Index) PossibleDuplicates(settings Settings) PossibleDuplicates {
+	// The background context is never done.
+	clusters, _, _ := idx.clusters(context.Background(), settings, nil)
+	return positionsToStrings(clusters, idx.entries)
+}
+
+// clusters is PossibleDuplicates returning the positions of the clustered
+// strings rather than the strings, together with every pair of different
+// strings that matched, ordered by position. Equal strings are clustered
+// without being paired. Pairs of different strings for which suppressed, if
+// not nil, returns true are never matched. Clustering stops with ctx's error
+// if ctx is done first.
+func (idx *Index) clusters(ctx context.Context, settings Settings, suppressed func(a, b string) bool) ([][]int, []pair, error) {
+	metric, threshold := settings.metricThreshold()
+	maxEdits, bounded := settings.maxEdits()
+	indexed := bounded && maxEdits <= idx.maxDistance
+	tokens, tokenized := idx.tokenIndex(metric, threshold)
+	histograms := newHistograms(idx.entries)
+	clusters := newDisjointSet(len(idx.entries))
+	// A pair may share several variants; once it has been visited there is
+	// nothing left to learn from comparing it again. A scan of every pair, or
+	// of the pairs sharing tokens, visits each once.
+	visited := make(map[[2]int]bool)
+	var pairs []pair
+	compared, pruned := 0, 0
+	compare := func(i, j int) {
+		a, b := idx.entries[i], idx.entries[j]
+		if a == b {
+			clusters.union(i, j)
+			return
+		}
+		if indexed {
+			if visited[[2]int{i, j}] {
+				return
+			}
+			visited[[2]int{i, j}] = true
+		}
+		if !compareLengths(a, b, settings.LengthThreshold) {
+			return
+		}
+		if suppressed != nil && suppressed(a, b) {
+			return
+		}
+		// Pairs that are certainly too far apart are not scored. Candidates
+		// from the index share a deletion variant, which their character
+		// counts almost never rule out, so only pairs from a scan of every
+		// pair have their characters counted.
+		if bounded && (lengthBound(a, b) > maxEdits || (!indexed && histogramBound(histograms.of(i), histograms.of(j)) > maxEdits)) {
+			pruned++
+			return
+		}
+		compared++
+		if !withinThreshold(metric, metric.Score(a, b), threshold) {
+			return
+		}
+		local, domain := scoreParts(metric, a, b)
+		if !settings.withinPartThresholds(local, domain) {
+			return
+		}
+		clusters.union(i, j)
+		pairs = append(pairs, pair{a: i, b: j, localScore: local, domainScore: domain})
+	}
+	// Positions are in ascending order, so each pair is compared with its
+	// lower position first.
+	comparePairs := func(positions []int) error {
+		if len(positions) < 2 {
+			return nil
+		}
+		for a := 0; a < len(positions); a++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			for b := a + 1; b < len(positions); b++ {
+				compare(positions[a], positions[b])
+			}
+		}
+		return nil
+	}
+	var err error
+	switch {
+	case indexed:
+		for _, positions := range idx.variants {
+			if err = comparePairs(positions); err != nil {
+				break
+			}
+		}
+	case tokenized:
+		err = tokens.pairs(ctx, threshold, settings.LengthThreshold, compare)
+	default:
+		err = comparePairs(idx.nonEmpty())
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	pairsCompared.Observe(float64(compared))
+	pairsPruned.Observe(float64(pruned))
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].a != pairs[j].a {
+			return pairs[i].a < pairs[j].a
+		}
+		return pairs[i].b < pairs[j].b
+	})
+	// Clusters too small to report are dropped with their pairs.
+	sets := [][]int{}
+	reported := make(map[int]bool)
+	for _, set := range clusters.sets() {
+		if len(set) >= settings.MinClusterSize {
+			sets = append(sets, set)
+			reported[clusters.find(set[0])] = true
+		}
+	}
+	var reportedPairs []pair
+	for _, p := range pairs {
+		if reported[clusters.find(p.a)] {
+			reportedPairs = append(reportedPairs, p)
+		}
+	}
+	return sets, reportedPairs, nil
+}
+
+// nonEmpty returns the positions of the indexed strings.
+func (idx *Index) nonEmpty() []int {
+	positions := make([]int, 0, len(idx.entries))
+	for i, s := range idx.entries {
+		if s != "" {
+			positions = append(positions, i)
+		}
+	}
+	return positions
+}
+
+// candidates returns, in index order, the positions of the strings sharing a
+// deletion variant with at most k deletions with s.
+func (idx *Index) candidates(s string, k int) []int {
+	var positions []int
+	for _, variant := range deletionVariants([]rune(s), k, nil) {
+		positions = append(positions, idx.variants[variant]...)
+	}
+	sort.Ints(positions)
+	unique := positions[:0]
+	for i, position := range positions {
+		if i == 0 || position != positions[i-1] {
+			unique = append(unique, position)
+		}
+	}
+	return unique
+}
+
+// tokenIndex returns the index of the indexed strings' tokens under metric,
+// building it the first time, and whether it can find the candidates that
+// match at threshold. It cannot when metric is not overlapBounded, or the
+// threshold does not require matching strings to share a token.
+func (idx *Index) tokenIndex(metric Metric, threshold float64) (*tokenIndex, bool) {
+	bounded, ok := metric.(overlapBounded)
+	if !ok || bounded.minOverlap(1, threshold) < 1 {
+		return nil, false
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	tokens, ok := idx.tokens[metric.Name()]
+	if !ok {
+		tokens = newTokenIndex(idx.entries, bounded)
+		idx.tokens[metric.Name()] = tokens
+	}
+	return tokens, true
+}
+
+func newTokenIndex(strs []string, metric overlapBounded) *tokenIndex {
+	t := &tokenIndex{
+		metric:  metric,
+		ids:     make(map[string]int32),
+		tokens:  make([][]int32, len(strs)),
+		lengths: make([]int, len(strs)),
+	}
+	tokens := make([][]string, len(strs))
+	frequency := make(map[string]int)
+	for i, s := range strs {
+		if s == "" {
+			continue
+		}
+		tokens[i] = metric.tokens(s)
+		t.lengths[i] = utf8.RuneCountInString(s)
+		for _, token := range tokens[i] {
+			frequency[token]++
+		}
+	}
+	// Equally common tokens are numbered in order of their value, so that
+	// the numbering does not depend on the order of a map.
+	distinct := make([]string, 0, len(frequency))
+	for token := range frequency {
+		distinct = append(distinct, token)
+	}
+	sort.Slice(distinct, func(i, j int) bool {
+		if frequency[distinct[i]] != frequency[distinct[j]] {
+			return frequency[distinct[i]] < frequency[distinct[j]]
+		}
+		return distinct[i] < distinct[j]
+	})
+	for id, token := range distinct {
+		t.ids[token] = int32(id)
+	}
+	for i, s := range strs {
+		if s != "" {
+			t.byLength = append(t.byLength, i)
+		}
+	}
+	sort.SliceStable(t.byLength, func(i, j int) bool {
+		return t.lengths[t.byLength[i]] < t.lengths[t.byLength[j]]
+	})
+	t.postings = make([][]tokenPosting, len(distinct))
+	for _, i := range t.byLength {
+		t.tokens[i] = t.number(tokens[i])
+		for rank, id := range t.tokens[i] {
+			t.postings[id] = append(t.postings[id], tokenPosting{position: i, rank: rank})
+		}
+	}
+	return t
+}
+
+// number returns the ids of tokens from the rarest to the commonest. Tokens
+// no indexed string has are the rarest of all, and numbered -1.
+func (t *tokenIndex) number(tokens []string) []int32 {
+	ids := make([]int32, len(tokens))
+	for i, token := range tokens {
+		if id, ok := t.ids[token]; ok {
+			ids[i] = id
+		} else {
+			ids[i] = -1
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// pairs calls visit once with each pair of positions of strings that may
+// match at threshold, lower position first: the strings sharing a token among
+// the first tokens of each (see tokenIndex) and enough tokens in all, whose
+// lengths in runes differ by at most lengthThreshold. It stops with ctx's
+// error if ctx is done first.
+func (t *tokenIndex) pairs(ctx context.Context, threshold float64, lengthThreshold int, visit func(i, j int)) error {
+	minOverlaps := make([]int, len(t.tokens))
+	for i, tokens := range t.tokens {
+		minOverlaps[i] = t.metric.minOverlap(len(tokens), threshold)
+	}
+	// Each string is paired with the strings before it from the shortest to
+	// the longest. probed[i] is j+1 once i has been considered for j, so that
+	// a pair sharing several tokens is visited once.
+	probed := make([]int, len(t.tokens))
+	for _, j := range t.byLength {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		tokens := t.tokens[j]
+		for _, id := range tokens[:len(tokens)-minOverlaps[j]+1] {
+			postings := t.postings[id]
+			first := sort.Search(len(postings), func(k int) bool {
+				return t.lengths[postings[k].position] >= t.lengths[j]-lengthThreshold
+			})
+			for _, posting := range postings[first:] {
+				i := posting.position
+				if i == j {
+					break
+				}
+				if posting.rank > len(t.tokens[i])-minOverlaps[i] || probed[i] == j+1 {
+					continue
+				}
+				probed[i] = j + 1
+				if overlap(t.tokens[i], tokens) < t.metric.minPairOverlap(len(t.tokens[i]), len(tokens), threshold) {
+					continue
+				}
+				if i < j {
+					visit(i, j)
+				} else {
+					visit(j, i)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// candidates returns, in index order, the positions of the strings that may
+// match s at threshold: those sharing a token with s among the first tokens
+// of each (see tokenIndex) and enough tokens in all.
+func (t *tokenIndex) candidates(s string, threshold float64) []int {
+	tokens := t.number(t.metric.tokens(s))
+	if len(tokens) == 0 {
+		return nil
+	}
+	minOverlap := t.metric.minOverlap(len(tokens), threshold)
+	seen := make(map[int]bool)
+	var positions []int
+	for _, id := range tokens[:len(tokens)-minOverlap+1] {
+		if id < 0 {
+			continue
+		}
+		for _, posting := range t.postings[id] {
+			i := posting.position
+			indexedMinOverlap := t.metric.minOverlap(len(t.tokens[i]), threshold)
+			if posting.rank > len(t.tokens[i])-indexedMinOverlap || seen[i] {
+				continue
+			}
+			seen[i] = true
+			if overlap(t.tokens[i], tokens) >= t.metric.minPairOverlap(len(t.tokens[i]), len(tokens), threshold) {
+				positions = append(positions, i)
+			}
+		}
+	}
+	sort.Ints(positions)
+	return positions
+}
+
+// overlap counts the tokens the ordered token ids a and b share.
+func overlap(a, b []int32) int {
+	shared := 0
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			shared++
+			i++
+			j++
+		}
+	}
+	return shared
+}
+
+// deletionVariants appends to dst the distinct hashes of runes and of every
+// sequence obtained by deleting up to k of them, and returns the result. The
+// variants are hashed rather than built as strings as there are many of
+// them; a collision only adds a candidate, which Search then rejects.
+func deletionVariants(runes []rune, k int, dst []uint64) []uint64 {
+	start := len(dst)
+	deleted := make([]bool, len(runes))
+	var enumerate func(from, remaining int)
+	enumerate = func(from, remaining int) {
+		dst = append(dst, hashUndeleted(runes, deleted))
+		if remaining == 0 {
+			return
+		}
+		for i := from; i < len(runes); i++ {
+			deleted[i] = true
+			enumerate(i+1, remaining-1)
+			deleted[i] = false
+		}
+	}
+	enumerate(0, k)
+
+	variants := dst[start:]
+	sort.Slice(variants, func(i, j int) bool { return variants[i] < variants[j] })
+	unique := variants[:0]
+	for i, variant := range variants {
+		if i == 0 || variant != variants[i-1] {
+			unique = append(unique, variant)
+		}
+	}
+	return dst[:start+len(unique)]
+}
+
+// hashUndeleted is the 64-bit FNV-1a hash of the runes not marked deleted.
+func hashUndeleted(runes []rune, deleted []bool) uint64 {
+	const (
+		offset64 = 14695981039346656037
+		prime64  = 1099511628211
+	)
+	hash := uint64(offset64)
+	for i, r := range runes {
+		if deleted[i] {
+			continue
+		}
+		for shift := uint(0); shift < 32; shift += 8 {
+			hash ^= uint64(byte(r >> shift))
+			hash *= prime64
+		}
+	}
+	return hash
+}
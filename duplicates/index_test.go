@@ -0,0 +1,230 @@
+package duplicates
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	slapi "github.com/slpeople/salesloftapi"
+)
+
+func TestIndexSearch(t *testing.T) {
+	index := NewIndex([]string{"dan@test.com", "dann@test.com", "", "and@test.com", "dam@test.com", "dan@test.com"}, 2)
+	searchTestData := []struct {
+		s        string
+		k        int
+		expected []Match
+	}{
+		{
+			s: "dan@test.com",
+			k: 0,
+			expected: []Match{
+				{Position: 0, Value: "dan@test.com", Distance: 0, Score: 0},
+				{Position: 5, Value: "dan@test.com", Distance: 0, Score: 0},
+			},
+		},
+		{
+			s: "dan@test.com",
+			k: 1,
+			expected: []Match{
+				{Position: 0, Value: "dan@test.com", Distance: 0, Score: 0},
+				{Position: 5, Value: "dan@test.com", Distance: 0, Score: 0},
+				// m is next to n, so dam@ is the likelier typo.
+				{Position: 4, Value: "dam@test.com", Distance: 1, Score: 1},
+				{Position: 1, Value: "dann@test.com", Distance: 1, Score: 1},
+			},
+		},
+		{
+			s: "adn@test.com",
+			k: 2,
+			expected: []Match{
+				{Position: 0, Value: "dan@test.com", Distance: 2, Score: 2},
+				{Position: 1, Value: "dann@test.com", Distance: 2, Score: 2},
+				{Position: 3, Value: "and@test.com", Distance: 2, Score: 2},
+				{Position: 5, Value: "dan@test.com", Distance: 2, Score: 2},
+			},
+		},
+		{
+			// Distances beyond the index's are found by comparing every string.
+			s: "d@test.com",
+			k: 3,
+			expected: []Match{
+				{Position: 0, Value: "dan@test.com", Distance: 2, Score: 2},
+				{Position: 3, Value: "and@test.com", Distance: 2, Score: 2},
+				{Position: 4, Value: "dam@test.com", Distance: 2, Score: 2},
+				{Position: 5, Value: "dan@test.com", Distance: 2, Score: 2},
+				{Position: 1, Value: "dann@test.com", Distance: 3, Score: 3},
+			},
+		},
+		{s: "", k: 1, expected: []Match{}},
+	}
+	for _, td := range searchTestData {
+		if result := index.Search(td.s, td.k); !cmp.Equal(result, td.expected) {
+			t.Fatalf("unexpected matches for %q within %d: \n\tresult: %v\n\texpect: %v\n", td.s, td.k, result, td.expected)
+		}
+	}
+}
+
+// TestIndexSearchIsComplete checks the index against comparing every string.
+func TestIndexSearchIsComplete(t *testing.T) {
+	addresses := syntheticEmailAddresses(2000)
+	for k := 0; k <= 2; k++ {
+		index := NewIndex(addresses, k)
+		for _, query := range addresses[:100] {
+			var expected []int
+			for i, s := range addresses {
+				if ComputeDistance(query, s) <= k {
+					expected = append(expected, i)
+				}
+			}
+			var result []int
+			for _, match := range index.Search(query, k) {
+				result = append(result, match.Position)
+			}
+			if len(result) != len(expected) {
+				t.Fatalf("%q within %d: the index found %d strings, comparing every string found %d\n", query, k, len(result), len(expected))
+			}
+		}
+	}
+}
+
+// TestIndexTokensAreComplete checks the candidates found by the tokens
+// strings share against comparing every string.
+func TestIndexTokensAreComplete(t *testing.T) {
+	addresses := syntheticEmailAddresses(1000)
+	index := NewIndex(addresses, 1)
+	tokensTestData := []struct {
+		metric    Metric
+		threshold float64
+	}{
+		{metric: jaroWinklerMetric{}, threshold: 0.85},
+		{metric: jaroWinklerMetric{}, threshold: 0.92},
+		{metric: jaroWinklerMetric{}, threshold: 0.98},
+		{metric: jaccardMetric{q: 2}, threshold: 0.5},
+		{metric: jaccardMetric{q: 2}, threshold: 0.8},
+	}
+	for _, td := range tokensTestData {
+		if _, ok := index.tokenIndex(td.metric, td.threshold); !ok {
+			t.Fatalf("expected %s at %v to find candidates by their tokens\n", td.metric.Name(), td.threshold)
+		}
+		matchedPairs := 0
+		for i, a := range addresses {
+			for _, b := range addresses[i+1:] {
+				if a != b && compareLengths(a, b, DefaultSettings.LengthThreshold) && td.metric.Score(a, b) >= td.threshold {
+					matchedPairs++
+				}
+			}
+		}
+		settings := DefaultSettings
+		settings.Metric, settings.SimilarityThreshold = td.metric, td.threshold
+		_, pairs, _ := index.clusters(context.Background(), settings, nil)
+		if len(pairs) != matchedPairs {
+			t.Fatalf("%s at %v: the index matched %d pairs, comparing every pair matched %d\n", td.metric.Name(), td.threshold, len(pairs), matchedPairs)
+		}
+		for _, query := range addresses[:100] {
+			expected := 0
+			for _, s := range addresses {
+				if td.metric.Score(query, s) >= td.threshold {
+					expected++
+				}
+			}
+			result, _ := index.SearchMetric(context.Background(), query, td.metric, td.threshold)
+			if len(result) != expected {
+				t.Fatalf("%q at %s %v: the index found %d strings, comparing every string found %d\n", query, td.metric.Name(), td.threshold, len(result), expected)
+			}
+		}
+	}
+}
+
+func TestIndexStopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	index := NewIndex([]string{"dan@test.com", "dann@test.com", "dam@test.com"}, 1)
+	for _, metric := range []Metric{levenshteinMetric{}, jaroWinklerMetric{}} {
+		settings := DefaultSettings
+		settings.Metric = metric
+		if _, _, err := index.clusters(ctx, settings, nil); err != context.Canceled {
+			t.Fatalf("expected clustering with %s to stop, got %v\n", metric.Name(), err)
+		}
+		metric, threshold := settings.metricThreshold()
+		if _, err := index.SearchMetric(ctx, "dan@test.com", metric, threshold); err != context.Canceled {
+			t.Fatalf("expected searching with %s to stop, got %v\n", metric.Name(), err)
+		}
+	}
+}
+
+func TestDeletionVariants(t *testing.T) {
+	variantsTestData := []struct {
+		s        string
+		k        int
+		expected []string
+	}{
+		{s: "abc", k: 0, expected: []string{"abc"}},
+		{s: "abc", k: 1, expected: []string{"abc", "bc", "ac", "ab"}},
+		{s: "aab", k: 1, expected: []string{"aab", "ab", "aa"}},
+		{s: "ab", k: 3, expected: []string{"ab", "a", "b", ""}},
+		{s: "né", k: 1, expected: []string{"né", "n", "é"}},
+	}
+	for _, td := range variantsTestData {
+		expected := map[uint64]bool{}
+		for _, variant := range td.expected {
+			runes := []rune(variant)
+			expected[hashUndeleted(runes, make([]bool, len(runes)))] = true
+		}
+		result := map[uint64]bool{}
+		hashes := deletionVariants([]rune(td.s), td.k, nil)
+		for _, hash := range hashes {
+			result[hash] = true
+		}
+		if len(hashes) != len(expected) || !cmp.Equal(result, expected) {
+			t.Fatalf("unexpected variants of %q: got %d hashes, expected those of %q\n", td.s, len(hashes), td.expected)
+		}
+	}
+}
+
+// A pair sharing several deletion variants is only visited once, whether it
+// matches or not.
+func TestIndexClustersVisitsPairsOnce(t *testing.T) {
+	// abcd and abdc share the variants abc and abd, but are two edits apart.
+	strs := []string{"abcd@x.io", "abdc@x.io", "abcde@x.io"}
+	visits := make(map[[2]string]int)
+	clusters, _, _ := NewIndex(strs, 1).clusters(context.Background(), DefaultSettings, func(a, b string) bool {
+		visits[[2]string{a, b}]++
+		return false
+	})
+	for pair, count := range visits {
+		if count != 1 {
+			t.Fatalf("%s and %s were visited %d times\n", pair[0], pair[1], count)
+		}
+	}
+	if expected := [][]int{{0, 2}}; !cmp.Equal(clusters, expected) {
+		t.Fatalf("unexpected clusters: \n\tresult: %v\n\texpect: %v\n", clusters, expected)
+	}
+}
+
+func TestHandlerReusesIndexForSnapshot(t *testing.T) {
+	people := &slapi.People{{EmailAddress: "dan@test.com"}}
+	source := slapi.PeopleSourceFunc(func(ctx context.Context) (*slapi.People, time.Duration, error) {
+		return people, 0, nil
+	})
+	h := NewHandler(source, DefaultSettings)
+	_, first, _, _ := h.snapshotIndex(context.Background(), h.settings)
+	_, second, _, _ := h.snapshotIndex(context.Background(), h.settings)
+	if first != second {
+		t.Fatalf("expected the index to be reused for the same snapshot\n")
+	}
+	people = &slapi.People{{EmailAddress: "dan@test.com"}, {EmailAddress: "dann@test.com"}}
+	if _, third, _, _ := h.snapshotIndex(context.Background(), h.settings); third == first || third.Len() != 2 {
+		t.Fatalf("expected the index to be rebuilt for a new snapshot\n")
+	}
+}
+
+func BenchmarkIndexSearch100k(b *testing.B) {
+	addresses := syntheticEmailAddresses(100000)
+	index := NewIndex(addresses, 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		index.Search(addresses[i%len(addresses)], 1)
+	}
+}
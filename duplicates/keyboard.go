@@ -0,0 +1,152 @@
+package duplicates
+
+import (
+	"math"
+	"unicode"
+)
+
+const (
+	// adjacentKeyCost is the cost of substituting a character for one on a
+	// neighbouring key, or for the other character on the same key, which is
+	// a likelier typo than an arbitrary substitution.
+	adjacentKeyCost = 0.5
+	// maxKeyDistance is the furthest apart, in key widths, the centres of two
+	// neighbouring keys are. Keys on the same row are 1 apart and diagonal
+	// neighbours at most 1.25.
+	maxKeyDistance = 1.3
+)
+
+type (
+	// KeyboardLayout locates the characters typed on a keyboard so that
+	// neighbouring keys can be recognised.
+	KeyboardLayout struct {
+		name string
+		keys map[rune]keyPosition
+	}
+	keyPosition struct {
+		row int
+		x   float64
+	}
+	// keyboardRow lists the characters typed on one row of keys, without and
+	// with shift, and how far the row's first key is from the left edge.
+	keyboardRow struct {
+		offset           float64
+		unshifted, shift string
+	}
+)
+
+var (
+	// QWERTY is the US English layout.
+	QWERTY = newKeyboardLayout("qwerty", []keyboardRow{
+		{0, "`1234567890-=", "~!@#$%^&*()_+"},
+		{1.5, "qwertyuiop[]\\", "QWERTYUIOP{}|"},
+		{1.75, "asdfghjkl;'", "ASDFGHJKL:\""},
+		{2.25, "zxcvbnm,./", "ZXCVBNM<>?"},
+	})
+	// AZERTY is the French layout.
+	AZERTY = newKeyboardLayout("azerty", []keyboardRow{
+		{0, "²&é\"'(-è_çà)=", "²1234567890°+"},
+		{1.5, "azertyuiop^$", "AZERTYUIOP¨£"},
+		{1.75, "qsdfghjklmù*", "QSDFGHJKLM%µ"},
+		{1.25, "<wxcvbn,;:!", ">WXCVBN?./§"},
+	})
+	// Dvorak is the US Dvorak simplified layout.
+	Dvorak = newKeyboardLayout("dvorak", []keyboardRow{
+		{0, "`1234567890[]", "~!@#$%^&*(){}"},
+		{1.5, "',.pyfgcrl/=\\", "\"<>PYFGCRL?+|"},
+		{1.75, "aoeuidhtns-", "AOEUIDHTNS_"},
+		{2.25, ";qjkxbmwvz", ":QJKXBMWVZ"},
+	})
+)
+
+func newKeyboardLayout(name string, rows []keyboardRow) *KeyboardLayout {
+	layout := &KeyboardLayout{name: name, keys: make(map[rune]keyPosition)}
+	for row, keys := range rows {
+		for _, chars := range []string{keys.unshifted, keys.shift} {
+			for column, ch := range []rune(chars) {
+				if _, ok := layout.keys[ch]; !ok {
+					layout.keys[ch] = keyPosition{row: row, x: keys.offset + float64(column)}
+				}
+			}
+		}
+	}
+	return layout
+}
+
+// Name returns the layout's name, such as qwerty.
+func (l *KeyboardLayout) Name() string {
+	return l.name
+}
+
+// Adjacent reports whether a and b are typed on neighbouring keys, or on the
+// same key with and without shift.
+func (l *KeyboardLayout) Adjacent(a, b rune) bool {
+	if a == b {
+		return false
+	}
+	pa, ok := l.keys[a]
+	if !ok {
+		pa, ok = l.keys[unicode.ToLower(a)]
+	}
+	pb, okb := l.keys[b]
+	if !okb {
+		pb, okb = l.keys[unicode.ToLower(b)]
+	}
+	if !ok || !okb {
+		return false
+	}
+	dx, dy := pa.x-pb.x, float64(pa.row-pb.row)
+	return math.Sqrt(dx*dx+dy*dy) <= maxKeyDistance
+}
+
+// ComputeKeyboardDistance computes a Levenshtein distance in which
+// substituting a character typed on a key neighbouring the intended one, as
+// in jphn for john on a QWERTY keyboard, costs half as much as any other
+// edit. Lower distances are likelier to be typos.
+func ComputeKeyboardDistance(a, b string, layout *KeyboardLayout) float64 {
+	s1, s2 := []rune(a), []rune(b)
+	prev := make([]float64, len(s2)+1)
+	curr := make([]float64, len(s2)+1)
+	for j := range prev {
+		prev[j] = float64(j)
+	}
+	for i := 1; i <= len(s1); i++ {
+		curr[0] = float64(i)
+		for j := 1; j <= len(s2); j++ {
+			substitution := 0.0
+			switch {
+			case s1[i-1] == s2[j-1]:
+			case layout.Adjacent(s1[i-1], s2[j-1]):
+				substitution = adjacentKeyCost
+			default:
+				substitution = 1
+			}
+			curr[j] = math.Min(math.Min(prev[j]+1, curr[j-1]+1), prev[j-1]+substitution)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(s2)]
+}
+
+// keyboardMetric scores strings with ComputeKeyboardDistance.
+type keyboardMetric struct {
+	name   string
+	layout *KeyboardLayout
+}
+
+func (m keyboardMetric) Name() string     { return m.name }
+func (m keyboardMetric) Similarity() bool { return false }
+
+func (m keyboardMetric) Score(a, b string) float64 {
+	return ComputeKeyboardDistance(a, b, m.layout)
+}
+
+// fractionalDistance marks keyboard distances as counting adjacent-key slips
+// as half an edit.
+func (keyboardMetric) fractionalDistance() {}
+
+// maxEdits allows for every edit being a cheap substitution of a
+// neighbouring key.
+func (m keyboardMetric) maxEdits(threshold float64) int {
+	return int(threshold / adjacentKeyCost)
+}
@@ -0,0 +1,95 @@
+package duplicates
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestKeyboardLayoutAdjacent(t *testing.T) {
+	adjacentTestData := []struct {
+		layout   *KeyboardLayout
+		a, b     rune
+		expected bool
+	}{
+		{layout: QWERTY, a: 'o', b: 'p', expected: true},
+		{layout: QWERTY, a: 'o', b: 'i', expected: true},
+		{layout: QWERTY, a: 'q', b: 'a', expected: true},
+		{layout: QWERTY, a: 'w', b: 'a', expected: true},
+		{layout: QWERTY, a: 'g', b: 'b', expected: true},
+		{layout: QWERTY, a: 'n', b: 'm', expected: true},
+		{layout: QWERTY, a: 'a', b: 'A', expected: true},
+		{layout: QWERTY, a: '2', b: '@', expected: true},
+		{layout: QWERTY, a: 'a', b: 'a', expected: false},
+		{layout: QWERTY, a: 'q', b: 's', expected: false},
+		{layout: QWERTY, a: 'a', b: 'l', expected: false},
+		{layout: QWERTY, a: 'o', b: 'é', expected: false},
+		{layout: AZERTY, a: 'a', b: 'z', expected: true},
+		{layout: AZERTY, a: 'q', b: 'w', expected: true},
+		{layout: AZERTY, a: 'q', b: 'a', expected: true},
+		{layout: AZERTY, a: 'm', b: 'ù', expected: true},
+		{layout: AZERTY, a: 'o', b: 'p', expected: true},
+		{layout: AZERTY, a: 'q', b: 'e', expected: false},
+		{layout: Dvorak, a: 'a', b: 'o', expected: true},
+		{layout: Dvorak, a: 'h', b: 't', expected: true},
+		{layout: Dvorak, a: 'o', b: 'p', expected: false},
+	}
+	for _, td := range adjacentTestData {
+		if result := td.layout.Adjacent(td.a, td.b); result != td.expected {
+			t.Fatalf("%s: unexpected adjacency of %q and %q: %v\n", td.layout.Name(), td.a, td.b, result)
+		}
+	}
+}
+
+func TestComputeKeyboardDistance(t *testing.T) {
+	keyboardTestData := []struct {
+		a, b     string
+		layout   *KeyboardLayout
+		expected float64
+	}{
+		{a: "", b: "", layout: QWERTY, expected: 0},
+		{a: "john", b: "john", layout: QWERTY, expected: 0},
+		{a: "john", b: "jphn", layout: QWERTY, expected: 0.5},
+		{a: "john", b: "jxhn", layout: QWERTY, expected: 1},
+		{a: "john", b: "jhn", layout: QWERTY, expected: 1},
+		{a: "john", b: "jphm", layout: QWERTY, expected: 1},
+		{a: "dan@test.com", b: "dam@test.com", layout: QWERTY, expected: 0.5},
+		{a: "dan@test.com", b: "dam@test.com", layout: Dvorak, expected: 1},
+		{a: "dan@test.com", b: "dan@test.con", layout: AZERTY, expected: 1},
+		{a: "anne", b: "qnne", layout: AZERTY, expected: 0.5},
+		{a: "anne", b: "dnne", layout: AZERTY, expected: 1},
+		{a: "anne", b: "qnne", layout: QWERTY, expected: 0.5},
+		{a: "anne", b: "znne", layout: QWERTY, expected: 0.5},
+	}
+	for _, td := range keyboardTestData {
+		if result := ComputeKeyboardDistance(td.a, td.b, td.layout); result != td.expected {
+			t.Fatalf("%s: unexpected distance between %q and %q: \n\tresult: %v\n\texpect: %v\n", td.layout.Name(), td.a, td.b, result, td.expected)
+		}
+	}
+}
+
+// TestKeyboardMetricRanksTypos checks that of two addresses one edit away,
+// the one an adjacent-key slip explains is ranked first.
+func TestKeyboardMetricRanksTypos(t *testing.T) {
+	metric, err := LookupMetric(Keyboard)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	index := NewIndex([]string{"jxhn@test.com", "jphn@test.com", "jon@test.com"}, 2)
+	matches, err := index.SearchMetric(context.Background(), "john@test.com", metric, 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	var result []string
+	for _, match := range matches {
+		result = append(result, match.Value)
+	}
+	expected := []string{"jphn@test.com", "jxhn@test.com", "jon@test.com"}
+	if !cmp.Equal(result, expected) {
+		t.Fatalf("unexpected ranking: \n\tresult: %v\n\texpect: %v\n", result, expected)
+	}
+	if matches[0].Score != 0.5 {
+		t.Fatalf("expected the adjacent-key slip to score 0.5, got %v\n", matches[0].Score)
+	}
+}
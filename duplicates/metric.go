@@ -0,0 +1,395 @@
+package duplicates
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The names metrics are selected by, in config and the metric query parameter.
+const (
+	Levenshtein = "levenshtein"
+	Damerau     = "damerau"
+	JaroWinkler = "jarowinkler"
+	Hamming     = "hamming"
+	Jaccard     = "jaccard"
+	// Keyboard is the keyboard distance on a QWERTY keyboard; KeyboardAZERTY
+	// and KeyboardDvorak use the other layouts.
+	Keyboard       = "keyboard"
+	KeyboardAZERTY = "keyboard-azerty"
+	KeyboardDvorak = "keyboard-dvorak"
+)
+
+type (
+	// Metric scores how alike two strings are. Distance metrics score equal
+	// strings 0 and count the edits between them, so two strings are possible
+	// duplicates when their score is at most the threshold. Similarity metrics
+	// score between 0 and 1, equal strings scoring 1, so two strings are
+	// possible duplicates when their score is at least the threshold.
+	Metric interface {
+		Name() string
+		Score(a, b string) float64
+		Similarity() bool
+	}
+	// editBounded is implemented by metrics whose threshold limits the
+	// Levenshtein distance between matching strings, which lets an Index find
+	// their candidates. Other metrics compare every pair.
+	editBounded interface {
+		maxEdits(threshold float64) int
+	}
+	// overlapBounded is implemented by similarity metrics whose threshold
+	// requires matching strings to share some of their tokens, which lets an
+	// Index find their candidates by the tokens they share. tokens returns the
+	// distinct tokens of a string, minOverlap the fewest that a string with n
+	// tokens shares with any string it matches, and minPairOverlap the fewest
+	// that two matching strings with m and n tokens share.
+	overlapBounded interface {
+		tokens(s string) []string
+		minOverlap(n int, threshold float64) int
+		minPairOverlap(m, n int, threshold float64) int
+	}
+	// fractionalDistance is implemented by distance metrics whose scores,
+	// and so thresholds, need not be whole numbers.
+	fractionalDistance interface {
+		fractionalDistance()
+	}
+	// defaultThresholder is implemented by similarity metrics to provide a
+	// threshold when none is given. Distance metrics use the configured
+	// distance threshold.
+	defaultThresholder interface {
+		defaultThreshold() float64
+	}
+
+	levenshteinMetric struct{}
+	damerauMetric     struct{}
+	jaroWinklerMetric struct{}
+	hammingMetric     struct{}
+	jaccardMetric     struct{ q int }
+)
+
+var availableMetrics = map[string]Metric{
+	Levenshtein: levenshteinMetric{},
+	Damerau:     damerauMetric{},
+	JaroWinkler: jaroWinklerMetric{},
+	Hamming:     hammingMetric{},
+	Jaccard:     jaccardMetric{q: 2},
+
+	Keyboard:       keyboardMetric{name: Keyboard, layout: QWERTY},
+	KeyboardAZERTY: keyboardMetric{name: KeyboardAZERTY, layout: AZERTY},
+	KeyboardDvorak: keyboardMetric{name: KeyboardDvorak, layout: Dvorak},
+}
+
+// LookupMetric returns the metric with the given name.
+func LookupMetric(name string) (Metric, error) {
+	if metric, ok := availableMetrics[strings.ToLower(name)]; ok {
+		return metric, nil
+	}
+	return nil, fmt.Errorf("unknown metric %q, expected one of %s", name, strings.Join(MetricNames(), ", "))
+}
+
+// MetricNames lists the names of the available metrics.
+func MetricNames() []string {
+	names := make([]string, 0, len(availableMetrics))
+	for name := range availableMetrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DefaultThreshold is the threshold used with metric when none is given:
+// distanceThreshold for distance metrics and a metric specific value for
+// similarity metrics.
+func DefaultThreshold(metric Metric, distanceThreshold int) float64 {
+	if d, ok := metric.(defaultThresholder); ok {
+		return d.defaultThreshold()
+	}
+	return float64(distanceThreshold)
+}
+
+// ValidateThreshold reports whether threshold makes sense for metric.
+func ValidateThreshold(metric Metric, threshold float64) error {
+	switch {
+	case math.IsNaN(threshold):
+		return fmt.Errorf("the %s threshold must be a number", metric.Name())
+	case metric.Similarity() && (threshold < 0 || threshold > 1):
+		return fmt.Errorf("the %s threshold must be between 0 and 1, got %v", metric.Name(), threshold)
+	case !metric.Similarity() && threshold < 0:
+		return fmt.Errorf("the %s threshold must not be negative, got %v", metric.Name(), threshold)
+	}
+	return nil
+}
+
+// withinThreshold reports whether score makes two strings possible
+// duplicates under metric.
+func withinThreshold(metric Metric, score, threshold float64) bool {
+	if metric.Similarity() {
+		return score >= threshold
+	}
+	return score <= threshold
+}
+
+func (levenshteinMetric) Name() string                   { return Levenshtein }
+func (levenshteinMetric) Similarity() bool               { return false }
+func (levenshteinMetric) maxEdits(threshold float64) int { return int(threshold) }
+
+func (levenshteinMetric) Score(a, b string) float64 {
+	return float64(ComputeDistance(a, b))
+}
+
+func (damerauMetric) Name() string     { return Damerau }
+func (damerauMetric) Similarity() bool { return false }
+
+// maxEdits allows for every edit being a transposition, which Levenshtein
+// counts as two edits.
+func (damerauMetric) maxEdits(threshold float64) int { return 2 * int(threshold) }
+
+func (damerauMetric) Score(a, b string) float64 {
+	return float64(ComputeOSADistance(a, b))
+}
+
+func (hammingMetric) Name() string                   { return Hamming }
+func (hammingMetric) Similarity() bool               { return false }
+func (hammingMetric) maxEdits(threshold float64) int { return int(threshold) }
+
+func (hammingMetric) Score(a, b string) float64 {
+	return float64(ComputeHammingDistance(a, b))
+}
+
+func (jaroWinklerMetric) Name() string              { return JaroWinkler }
+func (jaroWinklerMetric) Similarity() bool          { return true }
+func (jaroWinklerMetric) defaultThreshold() float64 { return 0.92 }
+
+func (jaroWinklerMetric) Score(a, b string) float64 {
+	return ComputeJaroWinkler(a, b)
+}
+
+// tokens are the characters of s, each occurrence of a character being a
+// different token, as only equal characters are matched.
+func (jaroWinklerMetric) tokens(s string) []string {
+	occurrences := make(map[rune]int)
+	var tokens []string
+	for _, r := range s {
+		tokens = append(tokens, string(r)+strconv.Itoa(occurrences[r]))
+		occurrences[r]++
+	}
+	return tokens
+}
+
+// minOverlap and minPairOverlap follow from the Jaro similarity of strings
+// with m and n characters being at most (k/m+k/n+1)/3 for k matched
+// characters. Whatever the other string, k/m is at most 1.
+func (jaroWinklerMetric) minOverlap(n int, threshold float64) int {
+	return overlapAtLeast((3*minJaro(threshold) - 2) * float64(n))
+}
+
+func (jaroWinklerMetric) minPairOverlap(m, n int, threshold float64) int {
+	return overlapAtLeast((3*minJaro(threshold) - 1) * float64(m*n) / float64(m+n))
+}
+
+// minJaro returns the lowest Jaro similarity that reaches the Jaro-Winkler
+// threshold, as the prefix bonus makes up at most maxBonus of what the Jaro
+// similarity lacks of 1.
+func minJaro(threshold float64) float64 {
+	const maxBonus = jaroWinklerMaxPrefixLength * jaroWinklerPrefixScale
+	return 1 - (1-threshold)/(1-maxBonus)
+}
+
+func (jaccardMetric) Name() string              { return Jaccard }
+func (jaccardMetric) Similarity() bool          { return true }
+func (jaccardMetric) defaultThreshold() float64 { return 0.8 }
+
+func (m jaccardMetric) Score(a, b string) float64 {
+	return ComputeQGramJaccard(a, b, m.q)
+}
+
+func (m jaccardMetric) tokens(s string) []string {
+	var tokens []string
+	for gram := range qgrams(s, m.q) {
+		tokens = append(tokens, gram)
+	}
+	return tokens
+}
+
+// minOverlap is the threshold's share of the n q-grams, as two sets share at
+// least that share of the larger set's.
+func (jaccardMetric) minOverlap(n int, threshold float64) int {
+	return overlapAtLeast(threshold * float64(n))
+}
+
+// minPairOverlap follows from k shared q-grams out of m+n-k being at least the
+// threshold.
+func (jaccardMetric) minPairOverlap(m, n int, threshold float64) int {
+	return overlapAtLeast(threshold * float64(m+n) / (1 + threshold))
+}
+
+// overlapAtLeast returns the smallest whole number of tokens, if any, no
+// less than x. x is reduced by a rounding error first, as rounding it up too
+// far would rule out a match.
+func overlapAtLeast(x float64) int {
+	const roundingError = 1e-9
+	if n := int(math.Ceil(x - roundingError)); n > 0 {
+		return n
+	}
+	return 0
+}
+
+// ComputeOSADistance computes the optimal string alignment distance between
+// a and b: the Levenshtein distance, but counting a transposition of two
+// adjacent characters, as in dna@ for dan@, as a single edit. No substring
+// is edited more than once, which makes it a restricted Damerau-Levenshtein
+// distance.
+func ComputeOSADistance(a, b string) int {
+	s1, s2 := []rune(a), []rune(b)
+	// Three rows suffice: a transposition looks back two rows.
+	prev2 := make([]int, len(s2)+1)
+	prev := make([]int, len(s2)+1)
+	curr := make([]int, len(s2)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s1); i++ {
+		curr[0] = i
+		for j := 1; j <= len(s2); j++ {
+			cost := 1
+			if s1[i-1] == s2[j-1] {
+				cost = 0
+			}
+			curr[j] = min(min(prev[j]+1, curr[j-1]+1), prev[j-1]+cost)
+			if i > 1 && j > 1 && s1[i-1] == s2[j-2] && s1[i-2] == s2[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(s2)]
+}
+
+// ComputeHammingDistance counts the positions at which a and b differ. The
+// Hamming distance is only defined for strings of equal length, so each
+// character by which one string is longer also counts as a difference.
+func ComputeHammingDistance(a, b string) int {
+	s1, s2 := []rune(a), []rune(b)
+	if len(s1) > len(s2) {
+		s1, s2 = s2, s1
+	}
+	distance := len(s2) - len(s1)
+	for i := range s1 {
+		if s1[i] != s2[i] {
+			distance++
+		}
+	}
+	return distance
+}
+
+// Winkler's prefix scale, maximum prefix length and the Jaro similarity below
+// which no prefix bonus is given.
+const (
+	jaroWinklerPrefixScale     = 0.1
+	jaroWinklerMaxPrefixLength = 4
+	jaroWinklerBoostThreshold  = 0.7
+)
+
+// ComputeJaroWinkler computes the Jaro-Winkler similarity of a and b, which
+// favours strings that agree from the start. It is 1 for equal strings and 0
+// for strings with nothing in common.
+func ComputeJaroWinkler(a, b string) float64 {
+	s1, s2 := []rune(a), []rune(b)
+	if len(s1) == 0 && len(s2) == 0 {
+		return 1
+	}
+	if len(s1) == 0 || len(s2) == 0 {
+		return 0
+	}
+
+	window := max(len(s1), len(s2))/2 - 1
+	if window < 0 {
+		window = 0
+	}
+	matched1 := make([]bool, len(s1))
+	matched2 := make([]bool, len(s2))
+	matches := 0
+	for i := range s1 {
+		for j := max(0, i-window); j <= i+window && j < len(s2); j++ {
+			if !matched2[j] && s1[i] == s2[j] {
+				matched1[i], matched2[j] = true, true
+				matches++
+				break
+			}
+		}
+	}
+	if matches == 0 {
+		return 0
+	}
+	transpositions := 0
+	j := 0
+	for i := range s1 {
+		if !matched1[i] {
+			continue
+		}
+		for !matched2[j] {
+			j++
+		}
+		if s1[i] != s2[j] {
+			transpositions++
+		}
+		j++
+	}
+	m := float64(matches)
+	jaro := (m/float64(len(s1)) + m/float64(len(s2)) + (m-float64(transpositions/2))/m) / 3
+	if jaro < jaroWinklerBoostThreshold {
+		return jaro
+	}
+	prefix := 0
+	for prefix < jaroWinklerMaxPrefixLength && prefix < len(s1) && prefix < len(s2) && s1[prefix] == s2[prefix] {
+		prefix++
+	}
+	return jaro + float64(prefix)*jaroWinklerPrefixScale*(1-jaro)
+}
+
+// ComputeQGramJaccard computes the Jaccard similarity of the sets of q-grams
+// (substrings of q characters) of a and b: the number of q-grams they share
+// divided by the number of distinct q-grams in either. Strings shorter than q
+// are treated as a single q-gram.
+func ComputeQGramJaccard(a, b string, q int) float64 {
+	if a == b {
+		return 1
+	}
+	grams1, grams2 := qgrams(a, q), qgrams(b, q)
+	shared := 0
+	for gram := range grams1 {
+		if grams2[gram] {
+			shared++
+		}
+	}
+	union := len(grams1) + len(grams2) - shared
+	if union == 0 {
+		return 1
+	}
+	return float64(shared) / float64(union)
+}
+
+func qgrams(s string, q int) map[string]bool {
+	runes := []rune(s)
+	grams := make(map[string]bool)
+	if len(runes) == 0 {
+		return grams
+	}
+	if len(runes) < q {
+		grams[s] = true
+		return grams
+	}
+	for i := 0; i+q <= len(runes); i++ {
+		grams[string(runes[i:i+q])] = true
+	}
+	return grams
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
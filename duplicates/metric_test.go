@@ -0,0 +1,154 @@
+package duplicates
+
+import (
+	"math"
+	"testing"
+)
+
+func TestComputeOSADistance(t *testing.T) {
+	osaTestData := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "", b: "", expected: 0},
+		{a: "", b: "abc", expected: 3},
+		{a: "dan@", b: "dan@", expected: 0},
+		{a: "dan@", b: "dna@", expected: 1},
+		{a: "dan@", b: "dann@", expected: 1},
+		{a: "dan@", b: "dam@", expected: 1},
+		{a: "ca", b: "abc", expected: 3},
+		{a: "martha", b: "marhta", expected: 1},
+		{a: "ab", b: "ba", expected: 1},
+		{a: "né", b: "én", expected: 1},
+	}
+	for _, td := range osaTestData {
+		if result := ComputeOSADistance(td.a, td.b); result != td.expected {
+			t.Fatalf("unexpected distance between %q and %q: \n\tresult: %d\n\texpect: %d\n", td.a, td.b, result, td.expected)
+		}
+		if result := ComputeOSADistance(td.b, td.a); result != td.expected {
+			t.Fatalf("the distance between %q and %q is not symmetric: %d\n", td.b, td.a, result)
+		}
+	}
+}
+
+func TestComputeHammingDistance(t *testing.T) {
+	hammingTestData := []struct {
+		a, b     string
+		expected int
+	}{
+		{a: "", b: "", expected: 0},
+		{a: "karolin", b: "kathrin", expected: 3},
+		{a: "dan@", b: "dna@", expected: 2},
+		{a: "dan@", b: "dann@", expected: 2},
+		{a: "abc", b: "", expected: 3},
+	}
+	for _, td := range hammingTestData {
+		if result := ComputeHammingDistance(td.a, td.b); result != td.expected {
+			t.Fatalf("unexpected distance between %q and %q: \n\tresult: %d\n\texpect: %d\n", td.a, td.b, result, td.expected)
+		}
+	}
+}
+
+func TestComputeJaroWinkler(t *testing.T) {
+	jaroWinklerTestData := []struct {
+		a, b     string
+		expected float64
+	}{
+		{a: "", b: "", expected: 1},
+		{a: "abc", b: "", expected: 0},
+		{a: "abc", b: "xyz", expected: 0},
+		{a: "martha", b: "martha", expected: 1},
+		// Reference values from Winkler's paper.
+		{a: "martha", b: "marhta", expected: 0.961},
+		{a: "dwayne", b: "duane", expected: 0.840},
+		{a: "dixon", b: "dicksonx", expected: 0.813},
+	}
+	for _, td := range jaroWinklerTestData {
+		if result := ComputeJaroWinkler(td.a, td.b); math.Abs(result-td.expected) > 0.001 {
+			t.Fatalf("unexpected similarity of %q and %q: \n\tresult: %.3f\n\texpect: %.3f\n", td.a, td.b, result, td.expected)
+		}
+	}
+}
+
+func TestComputeQGramJaccard(t *testing.T) {
+	jaccardTestData := []struct {
+		a, b     string
+		q        int
+		expected float64
+	}{
+		{a: "", b: "", q: 2, expected: 1},
+		{a: "abc", b: "", q: 2, expected: 0},
+		{a: "abcd", b: "abcd", q: 2, expected: 1},
+		// {ab, bc, cd} and {ab, bc, ce}: 2 shared of 4.
+		{a: "abcd", b: "abce", q: 2, expected: 0.5},
+		{a: "a", b: "a", q: 2, expected: 1},
+		{a: "a", b: "ab", q: 2, expected: 0},
+		{a: "abab", b: "ab", q: 2, expected: 0.5},
+	}
+	for _, td := range jaccardTestData {
+		if result := ComputeQGramJaccard(td.a, td.b, td.q); math.Abs(result-td.expected) > 1e-9 {
+			t.Fatalf("unexpected similarity of %q and %q: \n\tresult: %v\n\texpect: %v\n", td.a, td.b, result, td.expected)
+		}
+	}
+}
+
+func TestLookupMetric(t *testing.T) {
+	for _, name := range MetricNames() {
+		metric, err := LookupMetric(name)
+		if err != nil || metric.Name() != name {
+			t.Fatalf("unable to look up %q: %v\n", name, err)
+		}
+	}
+	if _, err := LookupMetric("soundex"); err == nil {
+		t.Fatalf("expected an unknown metric to be an error\n")
+	}
+}
+
+func TestMetricSettings(t *testing.T) {
+	settingsTestData := []struct {
+		settings  Settings
+		metric    string
+		threshold float64
+		maxEdits  int
+		bounded   bool
+	}{
+		{settings: Settings{DistanceThreshold: 2}, metric: Levenshtein, threshold: 2, maxEdits: 2, bounded: true},
+		{settings: Settings{DistanceThreshold: 1, Metric: damerauMetric{}}, metric: Damerau, threshold: 1, maxEdits: 2, bounded: true},
+		{settings: Settings{DistanceThreshold: 1, Metric: hammingMetric{}}, metric: Hamming, threshold: 1, maxEdits: 1, bounded: true},
+		{settings: Settings{DistanceThreshold: 1, Metric: jaroWinklerMetric{}}, metric: JaroWinkler, threshold: 0.92},
+		{settings: Settings{Metric: jaccardMetric{q: 2}, SimilarityThreshold: 0.5}, metric: Jaccard, threshold: 0.5},
+	}
+	for _, td := range settingsTestData {
+		metric, threshold := td.settings.metricThreshold()
+		maxEdits, bounded := td.settings.maxEdits()
+		if metric.Name() != td.metric || threshold != td.threshold || maxEdits != td.maxEdits || bounded != td.bounded {
+			t.Fatalf("unexpected metric for %+v: %s %v %d %v\n", td.settings, metric.Name(), threshold, maxEdits, bounded)
+		}
+	}
+}
+
+func TestSettingsValidate(t *testing.T) {
+	negative, jaroWinklerThreshold, keyboardThreshold := -1.0, 0.9, 1.5
+	validateTestData := []struct {
+		settings Settings
+		valid    bool
+	}{
+		{settings: DefaultSettings, valid: true},
+		{settings: Settings{MinClusterSize: 3}, valid: true},
+		{settings: Settings{Metric: jaroWinklerMetric{}, MinClusterSize: 2, LocalThreshold: &jaroWinklerThreshold}, valid: true},
+		{settings: Settings{DistanceThreshold: -1, MinClusterSize: 2}, valid: false},
+		{settings: Settings{LengthThreshold: -1, MinClusterSize: 2}, valid: false},
+		{settings: Settings{DistanceThreshold: 1}, valid: false},
+		{settings: Settings{Metric: jaroWinklerMetric{}, SimilarityThreshold: 1.5, MinClusterSize: 2}, valid: false},
+		{settings: Settings{MinClusterSize: 2, DomainThreshold: &negative}, valid: false},
+		// Thresholds allowing more edits than an index is built for.
+		{settings: Settings{DistanceThreshold: 3, MinClusterSize: 2}, valid: true},
+		{settings: Settings{DistanceThreshold: 2, Metric: damerauMetric{}, MinClusterSize: 2}, valid: true},
+		{settings: Settings{DistanceThreshold: 1, Metric: availableMetrics[Keyboard], MinClusterSize: 2, Threshold: &keyboardThreshold}, valid: true},
+	}
+	for _, td := range validateTestData {
+		if err := td.settings.Validate(); (err == nil) != td.valid {
+			t.Fatalf("unexpected validation of %+v: %v\n", td.settings, err)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package duplicates
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// pairBuckets span the candidate pair counts of anything from a handful of
+// people to a few tens of thousands.
+var pairBuckets = []float64{10, 100, 1000, 1e4, 1e5, 1e6, 1e7, 1e8}
+
+var (
+	detectionDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "duplicates_detection_duration_seconds",
+		Help: "Time taken by each FindPossibleDuplicates run.",
+	})
+	pairsCompared = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "duplicates_candidate_pairs_compared",
+		Help:    "Candidate pairs whose edit distance was computed in each FindPossibleDuplicates run.",
+		Buckets: pairBuckets,
+	})
+	pairsPruned = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "duplicates_candidate_pairs_pruned",
+		Help:    "Candidate pairs ruled out by their lengths or character counts, without computing their edit distance, in each FindPossibleDuplicates run.",
+		Buckets: pairBuckets,
+	})
+	personBlocksSkipped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "duplicates_person_blocks_skipped_total",
+		Help: "Blocks of people sharing a blocking key that were too large to compare, even once split by email domain and first name.",
+	})
+)
@@ -0,0 +1,47 @@
+package duplicates
+
+import (
+	"github.com/slpeople/emails"
+)
+
+// The classifications of a pair of possible duplicate email addresses by the
+// parts they differ in. A typo in the domain, as in dan@tesst.com for
+// dan@test.com, likely means the same person, while a different local part,
+// as in dann@test.com, may be a different mailbox.
+const (
+	LocalTypo  = "local_typo"
+	DomainTypo = "domain_typo"
+	BothTypos  = "both"
+)
+
+// pair is two indexed strings found to be possible duplicates, with the
+// scores of their local parts and their domains.
+type pair struct {
+	a, b                    int
+	localScore, domainScore float64
+}
+
+// scoreParts scores the local parts and the domains of addresses a and b
+// under metric. A string without an @ is all local part.
+func scoreParts(metric Metric, a, b string) (local, domain float64) {
+	localA, domainA, _ := emails.Split(a)
+	localB, domainB, _ := emails.Split(b)
+	return metric.Score(localA, localB), metric.Score(domainA, domainB)
+}
+
+// classify returns the classification of addresses a and b by whether their
+// local parts, their domains or both differ. Equal addresses are not
+// classified.
+func classify(a, b string) string {
+	localA, domainA, _ := emails.Split(a)
+	localB, domainB, _ := emails.Split(b)
+	switch {
+	case localA != localB && domainA != domainB:
+		return BothTypos
+	case localA != localB:
+		return LocalTypo
+	case domainA != domainB:
+		return DomainTypo
+	}
+	return ""
+}
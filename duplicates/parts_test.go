@@ -0,0 +1,37 @@
+package duplicates
+
+import (
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	classifyTestData := []struct {
+		a, b     string
+		expected string
+	}{
+		{a: "dan@test.com", b: "dann@test.com", expected: LocalTypo},
+		{a: "dan@test.com", b: "dan@tesst.com", expected: DomainTypo},
+		{a: "dan@test.com", b: "dann@tesst.com", expected: BothTypos},
+		{a: "dan@test.com", b: "dan@test.com", expected: ""},
+		{a: "dan", b: "dann", expected: LocalTypo},
+		{a: "dan", b: "dan@test.com", expected: DomainTypo},
+	}
+	for _, td := range classifyTestData {
+		if result := classify(td.a, td.b); result != td.expected {
+			t.Fatalf("unexpected classification of %q and %q: \n\tresult: %q\n\texpect: %q\n", td.a, td.b, result, td.expected)
+		}
+	}
+}
+
+func TestScoreParts(t *testing.T) {
+	local, domain := scoreParts(levenshteinMetric{}, "dan@tesst.com", "dann@test.com")
+	if local != 1 || domain != 1 {
+		t.Fatalf("unexpected part scores: \n\tresult: %v %v\n\texpect: 1 1\n", local, domain)
+	}
+	// The whole addresses are two edits apart, but all of them are in the
+	// local part.
+	local, domain = scoreParts(levenshteinMetric{}, "dan@test.com", "dnn@test.com")
+	if local != 1 || domain != 0 {
+		t.Fatalf("unexpected part scores: \n\tresult: %v %v\n\texpect: 1 0\n", local, domain)
+	}
+}
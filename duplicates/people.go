@@ -0,0 +1,501 @@
+package duplicates
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/slpeople/emails"
+	"github.com/slpeople/nicknames"
+	"github.com/slpeople/phonetic"
+	slapi "github.com/slpeople/salesloftapi"
+)
+
+// The fields people are compared on.
+const (
+	EmailField    = "email"
+	NameField     = "name"
+	PhoneticField = "phonetic"
+	TitleField    = "title"
+)
+
+// maxBlockSize is the most people sharing a blocking key that are compared
+// with each other. Larger blocks, such as a very common surname, would cost
+// quadratic time and are split by blockSplitters; any part still too large
+// is skipped, though its members are still compared through their other
+// keys.
+const maxBlockSize = 500
+
+// blockSplitters divide a block too large to compare whole, in turn, into the
+// groups of its people that also share the domain of their primary email
+// address, and then their first name, so that people with a common surname
+// are still compared with their colleagues.
+var blockSplitters = []func(*slapi.SimplifiedPersonView) string{
+	func(person *slapi.SimplifiedPersonView) string {
+		_, domain, _ := emails.Split(emails.Canonicalize(person.EmailAddress))
+		return domain
+	},
+	func(person *slapi.SimplifiedPersonView) string {
+		return normaliseField(person.FirstName)
+	},
+}
+
+type (
+	// PersonWeights weigh the similarity of each field in a person match
+	// score.
+	PersonWeights struct {
+		Email    float64 `json:"email"`
+		Name     float64 `json:"name"`
+		Phonetic float64 `json:"phonetic"`
+		Title    float64 `json:"title"`
+	}
+	// PersonMatcher scores how likely two people are the same person from
+	// the weighted similarities of their email addresses, names, how their
+	// names sound and titles. First names, including those at the start of
+	// email local parts, that are nicknames of each other, such as Bob and
+	// Robert, compare as equal.
+	PersonMatcher struct {
+		weights       PersonWeights
+		threshold     float64
+		nicknames     *nicknames.Dictionary
+		emailDistance int
+	}
+	// FieldEvidence is the similarity, between 0 and 1, of the values two
+	// people have for a field, and the weight it was given. For email
+	// addresses the values are the most similar pair across all three email
+	// fields.
+	FieldEvidence struct {
+		Field      string    `json:"field"`
+		Values     [2]string `json:"values"`
+		Similarity float64   `json:"similarity"`
+		Weight     float64   `json:"weight"`
+	}
+	// PersonMatch is two people whose match score reached the threshold.
+	PersonMatch struct {
+		IDs      [2]int          `json:"ids"`
+		Score    float64         `json:"score"`
+		Evidence []FieldEvidence `json:"evidence"`
+	}
+	// PersonCluster is a group of people that are possibly the same person,
+	// with the matches they were merged from.
+	PersonCluster struct {
+		People  []slapi.SimplifiedPersonView `json:"people"`
+		Matches []PersonMatch                `json:"matches"`
+	}
+	// personPair is a PersonMatch with the positions of its people.
+	personPair struct {
+		a, b int
+		PersonMatch
+	}
+)
+
+// DefaultPersonWeights favour email addresses, which identify a person best,
+// over names and titles.
+var DefaultPersonWeights = PersonWeights{Email: 0.55, Name: 0.2, Phonetic: 0.15, Title: 0.1}
+
+// DefaultPersonThreshold is the match score at which two people are possibly
+// the same person.
+const DefaultPersonThreshold = 0.8
+
+// NewPersonMatcher creates a PersonMatcher with the given weights that
+// matches people scoring at least threshold, using the bundled nicknames and
+// the distance threshold of DefaultSettings for email addresses.
+func NewPersonMatcher(weights PersonWeights, threshold float64) *PersonMatcher {
+	return &PersonMatcher{
+		weights:       weights,
+		threshold:     threshold,
+		nicknames:     nicknames.Default(),
+		emailDistance: DefaultSettings.DistanceThreshold,
+	}
+}
+
+// SetNicknames sets the dictionary of nicknames first names are compared
+// with, in place of the bundled one.
+func (m *PersonMatcher) SetNicknames(dictionary *nicknames.Dictionary) {
+	m.nicknames = dictionary
+}
+
+// SetEmailDistance sets the Levenshtein distance within which two people's
+// canonical email addresses get them compared. Distances beyond those an
+// Index is built for are cut down to them.
+func (m *PersonMatcher) SetEmailDistance(distance int) {
+	m.emailDistance = distance
+}
+
+// ValidatePersonMatching reports whether weights and threshold can be used to
+// match people.
+func ValidatePersonMatching(weights PersonWeights, threshold float64) error {
+	switch {
+	case weights.Email < 0 || weights.Name < 0 || weights.Phonetic < 0 || weights.Title < 0:
+		return fmt.Errorf("person weights must not be negative, got %+v", weights)
+	case weights.Email+weights.Name+weights.Phonetic+weights.Title == 0:
+		return fmt.Errorf("at least one person weight must be positive")
+	case threshold <= 0 || threshold > 1:
+		return fmt.Errorf("the person threshold must be greater than 0 and at most 1, got %v", threshold)
+	}
+	return nil
+}
+
+// Compare scores how likely a and b are the same person. Fields that either
+// person lacks are left out, and the score is the weighted mean of the
+// similarities of the others, so it is between 0 and 1. The evidence lists
+// the fields compared.
+func (m *PersonMatcher) Compare(a, b *slapi.SimplifiedPersonView) (float64, []FieldEvidence) {
+	var evidence []FieldEvidence
+	if values, similarity, ok := m.emailSimilarity(a, b); ok {
+		evidence = append(evidence, FieldEvidence{Field: EmailField, Values: values, Similarity: similarity, Weight: m.weights.Email})
+	}
+	if values, similarity, ok := m.nameSimilarity(a, b); ok {
+		evidence = append(evidence, FieldEvidence{Field: NameField, Values: values, Similarity: similarity, Weight: m.weights.Name})
+	}
+	if values, similarity, ok := phoneticSimilarity(a, b); ok {
+		evidence = append(evidence, FieldEvidence{Field: PhoneticField, Values: values, Similarity: similarity, Weight: m.weights.Phonetic})
+	}
+	if titleA, titleB := normaliseField(a.Title), normaliseField(b.Title); titleA != "" && titleB != "" {
+		evidence = append(evidence, FieldEvidence{Field: TitleField, Values: [2]string{titleA, titleB}, Similarity: ComputeQGramJaccard(titleA, titleB, 2), Weight: m.weights.Title})
+	}
+	var score, weights float64
+	for _, e := range evidence {
+		score += e.Weight * e.Similarity
+		weights += e.Weight
+	}
+	if weights == 0 {
+		return 0, evidence
+	}
+	return score / weights, evidence
+}
+
+// Clusters compares the people that share a blocking key (see
+// blockingKeys), or have email addresses within the email distance of each
+// other, and merges those scoring at least the threshold into
+// clusters, as FindPossibleDuplicates does for email addresses. Clusters are
+// ordered by their first person, and matches by the positions of the people.
+// Comparing stops with ctx's error once ctx is done.
+func (m *PersonMatcher) Clusters(ctx context.Context, people slapi.People) ([]PersonCluster, error) {
+	blocks := make(map[string][]int)
+	for i := range people {
+		for _, key := range m.blockingKeys(&people[i]) {
+			blocks[key] = append(blocks[key], i)
+		}
+	}
+	m.addNearEmailBlocks(people, blocks)
+	compared := make(map[[2]int]bool)
+	clusters := newDisjointSet(len(people))
+	var pairs []personPair
+	var compare [][]int
+	for key, positions := range blocks {
+		split, skipped := splitBlock(people, positions, blockSplitters)
+		compare = append(compare, split...)
+		for _, block := range skipped {
+			personBlocksSkipped.Inc()
+			log.Printf("Not comparing %d people sharing a %s blocking key, too many even when split by email domain and first name\n", len(block), key[:strings.Index(key, ":")])
+		}
+	}
+	for _, positions := range compare {
+		for a := 0; a < len(positions); a++ {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			for b := a + 1; b < len(positions); b++ {
+				i, j := positions[a], positions[b]
+				if compared[[2]int{i, j}] {
+					continue
+				}
+				compared[[2]int{i, j}] = true
+				score, evidence := m.Compare(&people[i], &people[j])
+				if score < m.threshold {
+					continue
+				}
+				clusters.union(i, j)
+				pairs = append(pairs, personPair{a: i, b: j, PersonMatch: PersonMatch{
+					IDs:      [2]int{people[i].ID, people[j].ID},
+					Score:    score,
+					Evidence: evidence,
+				}})
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].a != pairs[j].a {
+			return pairs[i].a < pairs[j].a
+		}
+		return pairs[i].b < pairs[j].b
+	})
+
+	sets := clusters.sets()
+	clusterOf := make(map[int]int)
+	result := make([]PersonCluster, len(sets))
+	for i, set := range sets {
+		result[i] = PersonCluster{People: make([]slapi.SimplifiedPersonView, len(set)), Matches: []PersonMatch{}}
+		for j, position := range set {
+			result[i].People[j] = people[position]
+			clusterOf[position] = i
+		}
+	}
+	for _, p := range pairs {
+		cluster := &result[clusterOf[p.a]]
+		cluster.Matches = append(cluster.Matches, p.PersonMatch)
+	}
+	return result, nil
+}
+
+// splitBlock returns the blocks to compare of the people at positions: the
+// positions themselves if they are at most maxBlockSize, and otherwise the
+// groups splitters divide them into, in turn, until each is small enough.
+// Groups still too large once the splitters run out are returned as skipped.
+func splitBlock(people slapi.People, positions []int, splitters []func(*slapi.SimplifiedPersonView) string) (blocks, skipped [][]int) {
+	if len(positions) <= maxBlockSize {
+		return [][]int{positions}, nil
+	}
+	if len(splitters) == 0 {
+		return nil, [][]int{positions}
+	}
+	groups := make(map[string][]int)
+	var keys []string
+	for _, position := range positions {
+		key := splitters[0](&people[position])
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], position)
+	}
+	for _, key := range keys {
+		if len(groups[key]) < 2 {
+			continue
+		}
+		split, tooLarge := splitBlock(people, groups[key], splitters[1:])
+		blocks = append(blocks, split...)
+		skipped = append(skipped, tooLarge...)
+	}
+	return blocks, skipped
+}
+
+// addNearEmailBlocks adds a block for each canonical email address, holding
+// the people with an address within the email distance of it, so that
+// people whose only link is a typo in an address, such as dan@ and dann@,
+// are compared. The addresses are found with an Index over every address of
+// every person.
+func (m *PersonMatcher) addNearEmailBlocks(people slapi.People, blocks map[string][]int) {
+	distance := m.emailDistance
+	if distance > maxIndexDistance {
+		distance = maxIndexDistance
+	}
+	if distance <= 0 {
+		// Equal addresses already share a blocking key.
+		return
+	}
+	var addresses []string
+	var owners []int
+	for i := range people {
+		for _, address := range emailAddresses(&people[i]) {
+			addresses = append(addresses, emails.Canonicalize(address))
+			owners = append(owners, i)
+		}
+	}
+	index := NewIndex(addresses, distance)
+	for _, address := range addresses {
+		key := "near:" + address
+		if _, ok := blocks[key]; ok {
+			continue
+		}
+		var positions []int
+		for _, match := range index.Search(address, distance) {
+			positions = append(positions, owners[match.Position])
+		}
+		sort.Ints(positions)
+		block := positions[:0]
+		for _, position := range positions {
+			if len(block) == 0 || block[len(block)-1] != position {
+				block = append(block, position)
+			}
+		}
+		if len(block) > 1 {
+			blocks[key] = block
+		}
+	}
+}
+
+// blockingKeys returns the keys under which person is compared with others:
+// each canonical email address, the local part of each, also with its first
+// word replaced by the formal names it is a nickname of, the last name with
+// the first initial and with each formal first name, and the Double Metaphone
+// codes of the last and first names, each once. Two people are only compared
+// when they share a key, so that Smyth is compared with Smith, and
+// bob.jones@ with robert.jones@.
+func (m *PersonMatcher) blockingKeys(person *slapi.SimplifiedPersonView) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	add := func(key string) {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	for _, address := range emailAddresses(person) {
+		canonical := emails.Canonicalize(address)
+		add("email:" + canonical)
+		if local, _, ok := emails.Split(canonical); ok && local != "" {
+			add("local:" + local)
+			words := localWords(local)
+			for _, formal := range m.nicknames.Formal(words[0]) {
+				add("local:" + formal + strings.Join(words[1:], ""))
+			}
+		}
+	}
+	first, last := normaliseField(person.FirstName), normaliseField(person.LastName)
+	if last != "" {
+		initial := ""
+		if r, size := utf8.DecodeRuneInString(first); size > 0 {
+			initial = string(r)
+		}
+		add("name:" + last + " " + initial)
+		for _, formal := range m.nicknames.Formal(first) {
+			add("name:" + last + " " + formal)
+		}
+		lastPrimary, lastAlternate := phonetic.DoubleMetaphone(last)
+		firstPrimary, firstAlternate := phonetic.DoubleMetaphone(first)
+		for _, lastCode := range []string{lastPrimary, lastAlternate} {
+			for _, firstCode := range []string{firstPrimary, firstAlternate} {
+				if lastCode != "" {
+					add("phonetic:" + lastCode + " " + firstCode)
+				}
+			}
+		}
+	}
+	return keys
+}
+
+// phoneticSimilarity returns a's and b's full names and how alike they sound:
+// for their first names and their last names, the fraction of Soundex, NYSIIS
+// and Double Metaphone that give them the same code, averaged over the names
+// both people have.
+func phoneticSimilarity(a, b *slapi.SimplifiedPersonView) ([2]string, float64, bool) {
+	var similarity float64
+	var compared int
+	for _, names := range [][2]string{{a.FirstName, b.FirstName}, {a.LastName, b.LastName}} {
+		nameA, nameB := normaliseField(names[0]), normaliseField(names[1])
+		if phonetic.Soundex(nameA) == "" || phonetic.Soundex(nameB) == "" {
+			continue
+		}
+		var agree int
+		if phonetic.Soundex(nameA) == phonetic.Soundex(nameB) {
+			agree++
+		}
+		if phonetic.NYSIIS(nameA) == phonetic.NYSIIS(nameB) {
+			agree++
+		}
+		if soundAlike(nameA, nameB) {
+			agree++
+		}
+		similarity += float64(agree) / 3
+		compared++
+	}
+	if compared == 0 {
+		return [2]string{}, 0, false
+	}
+	return [2]string{fullName(a), fullName(b)}, similarity / float64(compared), true
+}
+
+// soundAlike reports whether a and b share a Double Metaphone code.
+func soundAlike(a, b string) bool {
+	primaryA, alternateA := phonetic.DoubleMetaphone(a)
+	primaryB, alternateB := phonetic.DoubleMetaphone(b)
+	return primaryA == primaryB || primaryA == alternateB || alternateA == primaryB || alternateA == alternateB
+}
+
+// emailSimilarity returns the most similar pair of a's and b's email
+// addresses, in any of their email fields, and its similarity: 1 when the
+// addresses are canonically equal, and otherwise one less their Levenshtein
+// distance as a fraction of the longer canonical address. Words of the local
+// parts that are nicknames of each other count as equal.
+func (m *PersonMatcher) emailSimilarity(a, b *slapi.SimplifiedPersonView) ([2]string, float64, bool) {
+	var best [2]string
+	bestSimilarity, found := 0.0, false
+	for _, addressA := range emailAddresses(a) {
+		canonicalA := emails.Canonicalize(addressA)
+		for _, addressB := range emailAddresses(b) {
+			canonicalB := m.equateNicknames(canonicalA, emails.Canonicalize(addressB))
+			similarity := levenshteinSimilarity(canonicalA, canonicalB, ComputeDistance(canonicalA, canonicalB))
+			if !found || similarity > bestSimilarity {
+				best, bestSimilarity, found = [2]string{addressA, addressB}, similarity, true
+			}
+		}
+	}
+	return best, bestSimilarity, found
+}
+
+// equateNicknames returns b with each word of its local part that can stand
+// for the same formal name as the word in its place in a's local part replaced
+// by a's word, so that robert.jones@ becomes bob.jones@ next to bob.jones@.
+// Local parts with different numbers of words are left as they are.
+func (m *PersonMatcher) equateNicknames(a, b string) string {
+	localA, _, okA := emails.Split(a)
+	localB, domainB, okB := emails.Split(b)
+	if !okA || !okB {
+		return b
+	}
+	wordsA, wordsB := localWords(localA), localWords(localB)
+	if len(wordsA) != len(wordsB) {
+		return b
+	}
+	for i := 0; i < len(wordsB); i += 2 {
+		if wordsA[i] != wordsB[i] && m.nicknames.Equivalent(wordsA[i], wordsB[i]) {
+			wordsB[i] = wordsA[i]
+		}
+	}
+	return strings.Join(wordsB, "") + "@" + domainB
+}
+
+// localWords splits an email local part into its words and the separators
+// between them, as in bob, ., jones for bob.jones, so that joining them gives
+// the local part back. Words are at the even positions; the first may be
+// empty when the local part starts with a separator.
+func localWords(local string) []string {
+	words := []string{""}
+	for _, r := range local {
+		separator := r == '.' || r == '_' || r == '-'
+		if separator != (len(words)%2 == 0) {
+			words = append(words, "")
+		}
+		words[len(words)-1] += string(r)
+	}
+	return words
+}
+
+// nameSimilarity returns a's and b's full names and their Jaro-Winkler
+// similarity, their first names comparing as equal when they are nicknames
+// of each other.
+func (m *PersonMatcher) nameSimilarity(a, b *slapi.SimplifiedPersonView) ([2]string, float64, bool) {
+	nameA, nameB := fullName(a), fullName(b)
+	if nameA == "" || nameB == "" {
+		return [2]string{}, 0, false
+	}
+	compared := nameB
+	if firstA := normaliseField(a.FirstName); firstA != "" && m.nicknames.Equivalent(firstA, b.FirstName) {
+		compared = strings.TrimSpace(firstA + " " + normaliseField(b.LastName))
+	}
+	return [2]string{nameA, nameB}, ComputeJaroWinkler(nameA, compared), true
+}
+
+// emailAddresses returns the person's email addresses that are not empty.
+func emailAddresses(person *slapi.SimplifiedPersonView) []string {
+	var addresses []string
+	for _, address := range []string{person.EmailAddress, person.SecondaryEmailAddress, person.PersonalEmailAddress} {
+		if strings.TrimSpace(address) != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+func fullName(person *slapi.SimplifiedPersonView) string {
+	return strings.TrimSpace(normaliseField(person.FirstName) + " " + normaliseField(person.LastName))
+}
+
+// normaliseField lowercases s and collapses its whitespace.
+func normaliseField(s string) string {
+	return strings.Join(strings.Fields(strings.ToLower(s)), " ")
+}
@@ -0,0 +1,236 @@
+package duplicates
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/slpeople/nicknames"
+	slapi "github.com/slpeople/salesloftapi"
+)
+
+var personTestPeople = slapi.People{
+	{ID: 1, FirstName: "Dan", LastName: "Test", EmailAddress: "dan@test.com", Title: "Sales Manager"},
+	{ID: 2, FirstName: "Daniel", LastName: "Test", EmailAddress: "daniel@work.com", PersonalEmailAddress: "Dan@Test.com"},
+	{ID: 3, FirstName: "John", LastName: "Doe", EmailAddress: "john.doe@acme.com"},
+	{ID: 4, FirstName: "Jon", LastName: "Doe", EmailAddress: "jon.doe@acme.com"},
+	{ID: 5, FirstName: "Katherine", LastName: "Smith", EmailAddress: "k.smith@acme.com", Title: "CEO"},
+	{ID: 6, FirstName: "Katherine", LastName: "Smith", EmailAddress: "ksmith@gmail.com", Title: "CEO"},
+	{ID: 7, FirstName: "Jane", LastName: "Roe", EmailAddress: "jane@roe.com"},
+}
+
+func TestPersonMatcherCompare(t *testing.T) {
+	compareTestData := []struct {
+		a, b     int
+		expected float64
+		fields   []string
+	}{
+		// The same address in different fields.
+		// Dan is a nickname of Daniel, although they do not sound alike.
+		{a: 0, b: 1, expected: (0.55*1 + 0.2*1 + 0.15*0.5) / 0.9, fields: []string{EmailField, NameField, PhoneticField}},
+		// Jon is a nickname of John, in the name and in the address.
+		{a: 2, b: 3, expected: 1, fields: []string{EmailField, NameField, PhoneticField}},
+		// The same name and title, but unrelated addresses.
+		{a: 4, b: 5, expected: (0.55*(1-float64(ComputeDistance("k.smith@acme.com", "ksmith@gmail.com"))/16) + 0.2 + 0.15 + 0.1) / 1, fields: []string{EmailField, NameField, PhoneticField, TitleField}},
+	}
+	matcher := NewPersonMatcher(DefaultPersonWeights, DefaultPersonThreshold)
+	for _, td := range compareTestData {
+		score, evidence := matcher.Compare(&personTestPeople[td.a], &personTestPeople[td.b])
+		if math.Abs(score-td.expected) > 1e-9 {
+			t.Fatalf("unexpected score for %d and %d: \n\tresult: %v\n\texpect: %v\n", td.a, td.b, score, td.expected)
+		}
+		var fields []string
+		for _, e := range evidence {
+			fields = append(fields, e.Field)
+		}
+		if !cmp.Equal(fields, td.fields) {
+			t.Fatalf("unexpected evidence for %d and %d: \n\tresult: %v\n\texpect: %v\n", td.a, td.b, fields, td.fields)
+		}
+	}
+}
+
+func TestPersonMatcherClusters(t *testing.T) {
+	clusterTestData := []struct {
+		weights   PersonWeights
+		threshold float64
+		expected  [][]int
+	}{
+		{weights: DefaultPersonWeights, threshold: DefaultPersonThreshold, expected: [][]int{{1, 2}, {3, 4}}},
+		// Names and titles alone match the Katherine Smiths, and Dan and
+		// Daniel as a nickname.
+		{weights: PersonWeights{Name: 1, Title: 1}, threshold: 0.95, expected: [][]int{{1, 2}, {3, 4}, {5, 6}}},
+		{weights: DefaultPersonWeights, threshold: 1, expected: [][]int{{3, 4}}},
+	}
+	for _, td := range clusterTestData {
+		clusters, _ := NewPersonMatcher(td.weights, td.threshold).Clusters(context.Background(), personTestPeople)
+		result := [][]int{}
+		for _, cluster := range clusters {
+			var ids []int
+			for _, person := range cluster.People {
+				ids = append(ids, person.ID)
+			}
+			result = append(result, ids)
+			if len(cluster.Matches) == 0 {
+				t.Fatalf("cluster %v has no matches\n", ids)
+			}
+		}
+		if !cmp.Equal(result, td.expected) {
+			t.Fatalf("unexpected clusters for %+v at %v: \n\tresult: %v\n\texpect: %v\n", td.weights, td.threshold, result, td.expected)
+		}
+	}
+}
+
+func TestPhoneticSimilarity(t *testing.T) {
+	phoneticTestData := []struct {
+		a, b     slapi.SimplifiedPersonView
+		expected float64
+		ok       bool
+	}{
+		// Soundex keeps the first letter, so it does not match Katherine with
+		// Catherine, and NYSIIS keeps the Y of Smyth.
+		{a: slapi.SimplifiedPersonView{FirstName: "Katherine", LastName: "Smyth"}, b: slapi.SimplifiedPersonView{FirstName: "Catherine", LastName: "Smith"}, expected: 2.0 / 3, ok: true},
+		{a: slapi.SimplifiedPersonView{FirstName: "Steven"}, b: slapi.SimplifiedPersonView{FirstName: "Stephen", LastName: "Jones"}, expected: 1, ok: true},
+		// Nicknames do not sound like the names they stand for.
+		{a: slapi.SimplifiedPersonView{FirstName: "Bob", LastName: "Jones"}, b: slapi.SimplifiedPersonView{FirstName: "Robert", LastName: "Jones"}, expected: 0.5, ok: true},
+		{a: slapi.SimplifiedPersonView{FirstName: "Bob"}, b: slapi.SimplifiedPersonView{LastName: "Jones"}, ok: false},
+	}
+	for _, td := range phoneticTestData {
+		_, similarity, ok := phoneticSimilarity(&td.a, &td.b)
+		if ok != td.ok || math.Abs(similarity-td.expected) > 1e-9 {
+			t.Fatalf("unexpected phonetic similarity of %+v and %+v: \n\tresult: %v %v\n\texpect: %v %v\n", td.a, td.b, similarity, ok, td.expected, td.ok)
+		}
+	}
+}
+
+// Katherine Smyth and Catherine Smith share neither an email address nor a
+// spelling of their names, and are only compared because they sound alike.
+func TestPersonMatcherClustersPhonetic(t *testing.T) {
+	people := slapi.People{
+		{ID: 1, FirstName: "Katherine", LastName: "Smyth", EmailAddress: "kathy@example.com"},
+		{ID: 2, FirstName: "Catherine", LastName: "Smith", EmailAddress: "csmith@acme.com"},
+	}
+	clusters, _ := NewPersonMatcher(PersonWeights{Name: 1, Phonetic: 1}, 0.75).Clusters(context.Background(), people)
+	if len(clusters) != 1 || len(clusters[0].People) != 2 {
+		t.Fatalf("unexpected clusters: \n\tresult: %+v\n\texpect: one cluster of both people\n", clusters)
+	}
+}
+
+// Bob Jones and Robert Jones share no blocking key but for the nickname, and
+// only match through it.
+func TestPersonMatcherNicknames(t *testing.T) {
+	people := slapi.People{
+		{ID: 1, FirstName: "Bob", LastName: "Jones", EmailAddress: "bob.jones@acme.com"},
+		{ID: 2, FirstName: "Robert", LastName: "Jones", EmailAddress: "robert.jones@acme.com"},
+	}
+	matcher := NewPersonMatcher(PersonWeights{Email: 1, Name: 1}, 1)
+	if clusters, _ := matcher.Clusters(context.Background(), people); len(clusters) != 1 {
+		t.Fatalf("unexpected clusters: \n\tresult: %+v\n\texpect: one cluster of both people\n", clusters)
+	}
+	matcher.SetNicknames(nicknames.NewDictionary(nil))
+	if clusters, _ := matcher.Clusters(context.Background(), people); len(clusters) != 0 {
+		t.Fatalf("unexpected clusters without nicknames: \n\tresult: %+v\n\texpect: none\n", clusters)
+	}
+}
+
+// Dan and Dann have no names, so they share no blocking key, and are only
+// compared because their email addresses are one edit apart.
+func TestPersonMatcherClustersNearEmails(t *testing.T) {
+	people := slapi.People{
+		{ID: 1, EmailAddress: "dan@test.com"},
+		{ID: 2, EmailAddress: "jane@roe.com"},
+		{ID: 3, PersonalEmailAddress: "dann@test.com"},
+	}
+	matcher := NewPersonMatcher(PersonWeights{Email: 1}, 0.9)
+	clusters, _ := matcher.Clusters(context.Background(), people)
+	if len(clusters) != 1 || len(clusters[0].Matches) != 1 || clusters[0].Matches[0].IDs != [2]int{1, 3} {
+		t.Fatalf("unexpected clusters: \n\tresult: %+v\n\texpect: one cluster of people 1 and 3\n", clusters)
+	}
+	matcher.SetEmailDistance(0)
+	if clusters, _ := matcher.Clusters(context.Background(), people); len(clusters) != 0 {
+		t.Fatalf("unexpected clusters without near email addresses: \n\tresult: %+v\n\texpect: none\n", clusters)
+	}
+}
+
+func TestPersonMatcherClustersCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewPersonMatcher(DefaultPersonWeights, DefaultPersonThreshold).Clusters(ctx, personTestPeople); err != context.Canceled {
+		t.Fatalf("expected comparing to stop once the context is done, got %v\n", err)
+	}
+}
+
+func TestSplitBlock(t *testing.T) {
+	var people slapi.People
+	var positions []int
+	add := func(n int, firstName, domain string) {
+		for i := 0; i < n; i++ {
+			positions = append(positions, len(people))
+			people = append(people, slapi.SimplifiedPersonView{FirstName: firstName, LastName: "Smith", EmailAddress: fmt.Sprintf("%s%d@%s", firstName, i, domain)})
+		}
+	}
+	splitTestData := []struct {
+		groups          func()
+		blocks, skipped []int
+	}{
+		{groups: func() { add(10, "john", "acme.com") }, blocks: []int{10}},
+		// A block too large is split by email domain.
+		{groups: func() { add(260, "john", "acme.com"); add(260, "john", "other.com") }, blocks: []int{260, 260}},
+		// Then by first name, skipping a group that is still too large.
+		{groups: func() { add(501, "john", "acme.com"); add(5, "jane", "acme.com"); add(1, "jim", "other.com") }, blocks: []int{5}, skipped: []int{501}},
+	}
+	for i, td := range splitTestData {
+		people, positions = nil, nil
+		td.groups()
+		blocks, skipped := splitBlock(people, positions, blockSplitters)
+		var blockSizes, skippedSizes []int
+		for _, block := range blocks {
+			blockSizes = append(blockSizes, len(block))
+		}
+		for _, block := range skipped {
+			skippedSizes = append(skippedSizes, len(block))
+		}
+		if !cmp.Equal(blockSizes, td.blocks) || !cmp.Equal(skippedSizes, td.skipped) {
+			t.Fatalf("%d: unexpected split: \n\tresult: %v, skipped %v\n\texpect: %v, skipped %v\n", i, blockSizes, skippedSizes, td.blocks, td.skipped)
+		}
+	}
+}
+
+func TestLocalWords(t *testing.T) {
+	localWordsTestData := []struct {
+		local    string
+		expected []string
+	}{
+		{local: "bob.jones", expected: []string{"bob", ".", "jones"}},
+		{local: "bob", expected: []string{"bob"}},
+		{local: "bob_r-jones", expected: []string{"bob", "_", "r", "-", "jones"}},
+		{local: ".bob..jones.", expected: []string{"", ".", "bob", "..", "jones", "."}},
+	}
+	for _, td := range localWordsTestData {
+		if result := localWords(td.local); !cmp.Equal(result, td.expected) {
+			t.Fatalf("unexpected words of %q: \n\tresult: %q\n\texpect: %q\n", td.local, result, td.expected)
+		}
+	}
+}
+
+func TestValidatePersonMatching(t *testing.T) {
+	validateTestData := []struct {
+		weights   PersonWeights
+		threshold float64
+		valid     bool
+	}{
+		{weights: DefaultPersonWeights, threshold: DefaultPersonThreshold, valid: true},
+		{weights: PersonWeights{Name: 1}, threshold: 1, valid: true},
+		{weights: PersonWeights{Email: -1, Name: 1}, threshold: 0.5, valid: false},
+		{weights: PersonWeights{Name: 1, Phonetic: -1}, threshold: 0.5, valid: false},
+		{weights: PersonWeights{}, threshold: 0.5, valid: false},
+		{weights: DefaultPersonWeights, threshold: 0, valid: false},
+		{weights: DefaultPersonWeights, threshold: 1.5, valid: false},
+	}
+	for _, td := range validateTestData {
+		if err := ValidatePersonMatching(td.weights, td.threshold); (err == nil) != td.valid {
+			t.Fatalf("unexpected validation of %+v at %v: %v\n", td.weights, td.threshold, err)
+		}
+	}
+}
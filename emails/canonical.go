@@ -0,0 +1,95 @@
+// Package emails normalises email addresses so that addresses which reach the
+// same mailbox compare equal, such as John.Doe+crm@gmail.com and
+// johndoe@googlemail.com.
+package emails
+
+import (
+	"strings"
+
+	"golang.org/x/net/idna"
+	"golang.org/x/text/unicode/norm"
+)
+
+type (
+	// Provider describes how a mail provider treats the addresses it
+	// delivers to.
+	Provider struct {
+		// Domain is the domain canonical addresses are given.
+		Domain string
+		// Aliases are other domains that deliver to the same mailboxes.
+		Aliases []string
+		// IgnoreDots is set when dots in the local part are ignored, so that
+		// john.doe and johndoe are the same mailbox.
+		IgnoreDots bool
+		// SubaddressSeparator, when set, starts a tag in the local part that
+		// is ignored on delivery, as in john+crm for john.
+		SubaddressSeparator string
+	}
+)
+
+// Providers lists the mail providers whose rules Canonicalize applies. Other
+// domains are only normalised, as their local parts may be significant.
+var Providers = []Provider{
+	{Domain: "gmail.com", Aliases: []string{"googlemail.com"}, IgnoreDots: true, SubaddressSeparator: "+"},
+	{Domain: "outlook.com", SubaddressSeparator: "+"},
+	{Domain: "hotmail.com", SubaddressSeparator: "+"},
+	{Domain: "live.com", SubaddressSeparator: "+"},
+	{Domain: "icloud.com", Aliases: []string{"me.com", "mac.com"}, SubaddressSeparator: "+"},
+	{Domain: "fastmail.com", SubaddressSeparator: "+"},
+	{Domain: "proton.me", Aliases: []string{"protonmail.com", "protonmail.ch", "pm.me"}, SubaddressSeparator: "+"},
+}
+
+// Canonicalize returns the canonical form of address. The address is trimmed,
+// normalised to Unicode NFKC and lowercased, an internationalised domain is
+// converted to its ASCII (punycode) form by the IDNA lookup rules, and the
+// rules of the domain's Provider are applied. A domain that IDNA rejects is
+// kept as it is. A string that is not an address is only trimmed, normalised
+// and lowercased.
+func Canonicalize(address string) string {
+	address = strings.ToLower(norm.NFKC.String(strings.TrimSpace(address)))
+	local, domain, ok := Split(address)
+	if !ok {
+		return address
+	}
+	domain = strings.TrimSuffix(domain, ".")
+	if ascii, err := idna.Lookup.ToASCII(domain); err == nil {
+		domain = ascii
+	}
+	if provider, ok := lookupProvider(domain); ok {
+		domain = provider.Domain
+		if provider.SubaddressSeparator != "" {
+			if i := strings.Index(local, provider.SubaddressSeparator); i > 0 {
+				local = local[:i]
+			}
+		}
+		if provider.IgnoreDots {
+			local = strings.Replace(local, ".", "", -1)
+		}
+	}
+	return local + "@" + domain
+}
+
+// Split splits address into its local part and domain at the last @, which
+// is the one that separates them as local parts may contain a quoted @. It
+// reports false when address has no @.
+func Split(address string) (local, domain string, ok bool) {
+	i := strings.LastIndex(address, "@")
+	if i < 0 {
+		return address, "", false
+	}
+	return address[:i], address[i+1:], true
+}
+
+func lookupProvider(domain string) (Provider, bool) {
+	for _, provider := range Providers {
+		if domain == provider.Domain {
+			return provider, true
+		}
+		for _, alias := range provider.Aliases {
+			if domain == alias {
+				return provider, true
+			}
+		}
+	}
+	return Provider{}, false
+}
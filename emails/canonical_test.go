@@ -0,0 +1,62 @@
+package emails
+
+import (
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	canonicalTestData := []struct {
+		address  string
+		expected string
+	}{
+		{address: "dan@test.com", expected: "dan@test.com"},
+		{address: "  Dan@Test.COM ", expected: "dan@test.com"},
+		{address: "dan@test.com.", expected: "dan@test.com"},
+		// Only providers known to ignore them have dots and subaddresses
+		// removed.
+		{address: "dan.test+crm@test.com", expected: "dan.test+crm@test.com"},
+		{address: "John.Doe+crm@gmail.com", expected: "johndoe@gmail.com"},
+		{address: "johndoe@googlemail.com", expected: "johndoe@gmail.com"},
+		{address: "john.doe+crm@outlook.com", expected: "john.doe@outlook.com"},
+		{address: "john+crm@me.com", expected: "john@icloud.com"},
+		{address: "john+crm@pm.me", expected: "john@proton.me"},
+		{address: "+crm@gmail.com", expected: "+crm@gmail.com"},
+		// Full-width and compatibility characters are normalised.
+		{address: "ｄａｎ＠ｔｅｓｔ．ｃｏｍ", expected: "dan@test.com"},
+		{address: "ﬁn@test.com", expected: "fin@test.com"},
+		// Internationalised domains are converted to punycode.
+		{address: "dan@bücher.de", expected: "dan@xn--bcher-kva.de"},
+		{address: "dan@BÜCHER.de", expected: "dan@xn--bcher-kva.de"},
+		{address: "dan@xn--bcher-kva.de", expected: "dan@xn--bcher-kva.de"},
+		{address: "dan@münchen.de", expected: "dan@xn--mnchen-3ya.de"},
+		{address: "dan@他们为什么不说中文.cn", expected: "dan@xn--ihqwcrb4cv8a8dqg056pqjye.cn"},
+		// Domains that IDNA rejects, such as one with a label starting with
+		// a hyphen, are kept as they are.
+		{address: "dan@-bücher.de", expected: "dan@-bücher.de"},
+		{address: "Not An Address", expected: "not an address"},
+		{address: "", expected: ""},
+	}
+	for _, td := range canonicalTestData {
+		if result := Canonicalize(td.address); result != td.expected {
+			t.Fatalf("unexpected canonical address: \n\taddress: %q\n\tresult: %q\n\texpect: %q\n", td.address, result, td.expected)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	splitTestData := []struct {
+		address       string
+		local, domain string
+		ok            bool
+	}{
+		{address: "dan@test.com", local: "dan", domain: "test.com", ok: true},
+		{address: `"dan@home"@test.com`, local: `"dan@home"`, domain: "test.com", ok: true},
+		{address: "dan", local: "dan", ok: false},
+	}
+	for _, td := range splitTestData {
+		local, domain, ok := Split(td.address)
+		if local != td.local || domain != td.domain || ok != td.ok {
+			t.Fatalf("unexpected split of %q: \n\tresult: %q %q %v\n\texpect: %q %q %v\n", td.address, local, domain, ok, td.local, td.domain, td.ok)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+// Package health serves the liveness and readiness endpoints used by
+// orchestrators such as Kubernetes to decide whether to restart the service
+// or send it traffic.
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+const (
+	statusOK          = "ok"
+	statusUnavailable = "unavailable"
+)
+
+var (
+	errShuttingDown = errors.New("the service is shutting down")
+)
+
+type (
+	// Check reports whether a dependency is usable.
+	Check func(ctx context.Context) error
+	// Handler serves liveness and readiness. The service is live as long as
+	// it can answer; it is ready when every readiness check passes and it is
+	// not shutting down.
+	Handler struct {
+		mu           sync.Mutex
+		names        []string
+		checks       map[string]Check
+		shuttingDown bool
+	}
+	// StatusResponse describes the outcome of a probe and, for readiness,
+	// of each check.
+	StatusResponse struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks,omitempty"`
+
+		httpStatusCode int
+	}
+)
+
+// NewHandler creates a Handler without any readiness checks.
+func NewHandler() *Handler {
+	return &Handler{checks: map[string]Check{}}
+}
+
+// AddReadinessCheck adds a named check to the readiness probe.
+func (h *Handler) AddReadinessCheck(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.checks[name]; !ok {
+		h.names = append(h.names, name)
+	}
+	h.checks[name] = check
+}
+
+// SetShuttingDown makes the readiness probe fail so that no new traffic is
+// routed to the service while it drains.
+func (h *Handler) SetShuttingDown() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.shuttingDown = true
+}
+
+func (h *Handler) LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	render.Render(w, r, &StatusResponse{Status: statusOK, httpStatusCode: http.StatusOK})
+}
+
+func (h *Handler) ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	names := append([]string(nil), h.names...)
+	checks := make(map[string]Check, len(h.checks))
+	for name, check := range h.checks {
+		checks[name] = check
+	}
+	shuttingDown := h.shuttingDown
+	h.mu.Unlock()
+
+	resp := &StatusResponse{Status: statusOK, Checks: map[string]string{}, httpStatusCode: http.StatusOK}
+	if shuttingDown {
+		resp.Status = statusUnavailable
+		resp.Checks["shutdown"] = errShuttingDown.Error()
+		resp.httpStatusCode = http.StatusServiceUnavailable
+	}
+	for _, name := range names {
+		if err := checks[name](r.Context()); err != nil {
+			resp.Status = statusUnavailable
+			resp.Checks[name] = err.Error()
+			resp.httpStatusCode = http.StatusServiceUnavailable
+		} else {
+			resp.Checks[name] = statusOK
+		}
+	}
+	render.Render(w, r, resp)
+}
+
+func (s *StatusResponse) Render(w http.ResponseWriter, r *http.Request) error {
+	render.Status(r, s.httpStatusCode)
+	return nil
+}
+
+// Throttle runs check at most once per interval, reporting the previous
+// result in between. This keeps frequent probes from spending the SalesLoft
+// API's rate limit. Only one check runs at a time: probes arriving while it
+// runs report the previous result, or wait for the check if there is none.
+func Throttle(check Check, interval time.Duration) Check {
+	var (
+		mu       sync.Mutex
+		checked  time.Time
+		result   error
+		inFlight chan struct{}
+	)
+	return func(ctx context.Context) error {
+		mu.Lock()
+		for inFlight != nil || (!checked.IsZero() && time.Since(checked) < interval) {
+			if !checked.IsZero() {
+				err := result
+				mu.Unlock()
+				return err
+			}
+			done := inFlight
+			mu.Unlock()
+			select {
+			case <-done:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			mu.Lock()
+		}
+		done := make(chan struct{})
+		inFlight = done
+		mu.Unlock()
+
+		err := check(ctx)
+		mu.Lock()
+		// A probe that gave up says nothing about the dependency.
+		if ctx.Err() == nil {
+			result, checked = err, time.Now()
+		}
+		inFlight = nil
+		mu.Unlock()
+		close(done)
+		return err
+	}
+}
@@ -0,0 +1,118 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func probe(handler http.HandlerFunc) (int, StatusResponse) {
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/", nil))
+	var resp StatusResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	return w.Code, resp
+}
+
+func TestReadinessHandler(t *testing.T) {
+	upstreamErr := errors.New("connection refused")
+	var upstream error
+	h := NewHandler()
+	h.AddReadinessCheck("salesloft", func(ctx context.Context) error { return upstream })
+	h.AddReadinessCheck("cache", func(ctx context.Context) error { return nil })
+
+	code, resp := probe(h.ReadinessHandler)
+	expected := StatusResponse{Status: "ok", Checks: map[string]string{"salesloft": "ok", "cache": "ok"}}
+	if code != http.StatusOK || !cmp.Equal(resp, expected, cmp.AllowUnexported(StatusResponse{})) {
+		t.Fatalf("expected the service to be ready: %d %#v\n", code, resp)
+	}
+
+	upstream = upstreamErr
+	code, resp = probe(h.ReadinessHandler)
+	expected = StatusResponse{Status: "unavailable", Checks: map[string]string{"salesloft": "connection refused", "cache": "ok"}}
+	if code != http.StatusServiceUnavailable || !cmp.Equal(resp, expected, cmp.AllowUnexported(StatusResponse{})) {
+		t.Fatalf("expected the failing check to be reported: %d %#v\n", code, resp)
+	}
+
+	upstream = nil
+	h.SetShuttingDown()
+	if code, resp = probe(h.ReadinessHandler); code != http.StatusServiceUnavailable || resp.Checks["shutdown"] == "" {
+		t.Fatalf("expected the service not to be ready while shutting down: %d %#v\n", code, resp)
+	}
+	if code, _ = probe(h.LivenessHandler); code != http.StatusOK {
+		t.Fatalf("expected the service to stay live while shutting down, got %d\n", code)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	calls := 0
+	check := Throttle(func(ctx context.Context) error {
+		calls++
+		return nil
+	}, 50*time.Millisecond)
+
+	for i := 0; i < 5; i++ {
+		check(context.Background())
+	}
+	if calls != 1 {
+		t.Fatalf("expected one check within the interval, got %d\n", calls)
+	}
+	time.Sleep(60 * time.Millisecond)
+	check(context.Background())
+	if calls != 2 {
+		t.Fatalf("expected another check after the interval, got %d\n", calls)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	abandonedCalls := 0
+	abandoned := Throttle(func(ctx context.Context) error {
+		abandonedCalls++
+		return ctx.Err()
+	}, time.Hour)
+	abandoned(ctx)
+	if err := abandoned(context.Background()); err != nil || abandonedCalls != 2 {
+		t.Fatalf("expected an abandoned probe not to be remembered: %d calls, %v\n", abandonedCalls, err)
+	}
+}
+
+func TestThrottleDoesNotQueueBehindASlowCheck(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	errSlow := errors.New("slow")
+	check := Throttle(func(ctx context.Context) error {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return nil
+		}
+		<-release
+		return errSlow
+	}, time.Millisecond)
+	check(context.Background())
+	time.Sleep(5 * time.Millisecond)
+
+	// The second check hangs; probes meanwhile get the first result at once.
+	finished := make(chan error, 1)
+	go func() { finished <- check(context.Background()) }()
+	for atomic.LoadInt32(&calls) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 3; i++ {
+		if err := check(context.Background()); err != nil {
+			t.Fatalf("expected the previous result while a check is running, got %v\n", err)
+		}
+	}
+	close(release)
+	if err := <-finished; err != errSlow || atomic.LoadInt32(&calls) != 2 {
+		t.Fatalf("unexpected slow check: %v after %d calls\n", err, atomic.LoadInt32(&calls))
+	}
+	if err := check(context.Background()); err != errSlow {
+		t.Fatalf("expected the slow check's result to be remembered, got %v\n", err)
+	}
+}
@@ -1,16 +1,27 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
 	app "github.com/slpeople/app"
 	chars "github.com/slpeople/characters"
+	config "github.com/slpeople/config"
+	decisions "github.com/slpeople/decisions"
+	domains "github.com/slpeople/domains"
 	dupes "github.com/slpeople/duplicates"
+	health "github.com/slpeople/health"
+	metrics "github.com/slpeople/metrics"
+	nicknames "github.com/slpeople/nicknames"
 	slapi "github.com/slpeople/salesloftapi"
 
 	"github.com/go-chi/chi"
@@ -19,45 +30,179 @@ import (
 )
 
 const (
-	// TODO: Make this configurable
-	salesLoftApiURL = "https://api.salesloft.com/v2/people.json"
-)
-
-var (
-	apikey = flag.String("apikey", "", "SalesLoft API Key for communications with SalesLoft API (https://developers.salesloft.com/api.html)")
-	port   = flag.String("port", "3000", "The port for the service. The default value is 3000.")
+	// salesLoftCheckInterval is how often the readiness probe checks that the
+	// SalesLoft API is reachable.
+	salesLoftCheckInterval = 30 * time.Second
 )
 
 func main() {
-	// Parse the flags and validate their input.
-	flag.Parse()
-	if *apikey == "" {
-		fmt.Fprintf(os.Stderr, "An API Key is required. Please obtain a SalesLoft API key from your account or contact SalesLoft Support (support@salesloft.com) for assistance.")
+	// Gather the configuration from the config file, environment and flags, and validate it.
+	cfg, err := config.Load(os.Args[0], os.Args[1:], os.Getenv)
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if _, err := duplicatesSettings(cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n\t%v\n", err)
 		os.Exit(1)
-	} else {
-		log.Printf("Using API key: %s\n", *apikey)
 	}
-	if *port == "" {
-		fmt.Fprintf(os.Stderr, "The port was set to empty string. :(")
-		os.Exit(2)
-	} else {
-		log.Printf("Using port: %s\n", *port)
+	nicknameDictionary := nicknames.Default()
+	if cfg.Duplicates.People.NicknamesFile != "" {
+		if nicknameDictionary, err = nicknames.Load(cfg.Duplicates.People.NicknamesFile); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid configuration:\n\t%v\n", err)
+			os.Exit(1)
+		}
+	}
+	decisionStore := decisions.NewStore()
+	if cfg.Duplicates.DecisionsFile != "" {
+		if decisionStore, err = decisions.Open(cfg.Duplicates.DecisionsFile); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid configuration:\n\t%v\n", err)
+			os.Exit(1)
+		}
+	}
+	defer decisionStore.Close()
+	for _, warning := range cfg.Warnings() {
+		log.Printf("Warning: %s\n", warning)
+	}
+	log.Printf("Using API key: %s\n", cfg.APIKey)
+	log.Printf("Using port: %s\n", cfg.Port)
+	log.Printf("Using SalesLoft API: %s\n", cfg.PeopleURL())
+	if cfg.Duplicates.DecisionsFile != "" {
+		log.Printf("Using decisions file: %s\n", cfg.Duplicates.DecisionsFile)
+	}
+
+	client := slapi.NewClient(string(cfg.APIKey), cfg.PeopleURL(), cfg.SalesLoft.Concurrency)
+	client.SetPageSize(cfg.SalesLoft.PageSize)
+	client.SetTimeout(time.Duration(cfg.SalesLoft.Timeout))
+	client.SetRetryPolicy(slapi.RetryPolicy{
+		MaxAttempts: cfg.SalesLoft.Retry.Attempts,
+		BaseDelay:   time.Duration(cfg.SalesLoft.Retry.BaseDelay),
+		MaxDelay:    time.Duration(cfg.SalesLoft.Retry.MaxDelay),
+	})
+	// Confirm the API key before serving so that a bad key is reported once,
+	// clearly, rather than as a failure on every request.
+	err = client.CheckAPIKey(context.Background())
+	if err == slapi.ErrInvalidAPIKey {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Printf("Unable to reach the SalesLoft API to check the API key: %v\n", err)
+	}
+	source := slapi.NewPeopleCache(client.ListPeopleContext, time.Duration(cfg.Cache.TTL))
+	// Fill the cache now so that the service becomes ready without waiting for the first request.
+	go source.Snapshot(context.Background())
+
+	healthHandler := health.NewHandler()
+	healthHandler.AddReadinessCheck("salesloft", health.Throttle(client.CheckAPIKey, salesLoftCheckInterval))
+	healthHandler.AddReadinessCheck("cache", source.Check)
+
+	listener, err := net.Listen("tcp", ":"+cfg.Port)
+	if err != nil {
+		log.Fatalf("Unable to listen on port %s: %v\n", cfg.Port, err)
+	}
+	server := &http.Server{
+		Handler:      newRouter(source, healthHandler, cfg, nicknameDictionary, decisionStore),
+		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout),
+		WriteTimeout: time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:  time.Duration(cfg.Server.IdleTimeout),
+	}
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	if err := serve(server, listener, healthHandler, time.Duration(cfg.Server.ShutdownTimeout), stop); err != nil {
+		log.Fatalf("The server stopped unexpectedly: %v\n", err)
+	}
+	log.Printf("Shut down cleanly\n")
+}
+
+// serve runs server on listener until a signal arrives on stop. It then
+// fails the readiness probe and waits up to shutdownTimeout for in-flight
+// requests to finish; zero waits for as long as they take.
+func serve(server *http.Server, listener net.Listener, healthHandler *health.Handler, shutdownTimeout time.Duration, stop <-chan os.Signal) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Serve(listener)
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-stop:
+		log.Printf("Received %v, draining in-flight requests\n", sig)
 	}
 
-	// Create the router, setup middleware, and establish routes and handlers.
+	healthHandler.SetShuttingDown()
+	ctx := context.Background()
+	if shutdownTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, shutdownTimeout)
+		defer cancel()
+	}
+	return server.Shutdown(ctx)
+}
+
+// duplicatesSettings returns the settings possible duplicate email addresses
+// are found with by default, and an error if the configuration's metric is
+// unknown or its thresholds do not suit the metric.
+func duplicatesSettings(cfg *config.Config) (dupes.Settings, error) {
+	metric, err := dupes.LookupMetric(cfg.Duplicates.Metric)
+	if err != nil {
+		return dupes.Settings{}, err
+	}
+	settings := dupes.Settings{
+		DistanceThreshold:   cfg.Duplicates.DistanceThreshold,
+		LengthThreshold:     cfg.Duplicates.LengthThreshold,
+		MinClusterSize:      cfg.Duplicates.MinClusterSize,
+		Metric:              metric,
+		SimilarityThreshold: cfg.Duplicates.SimilarityThreshold,
+		LocalThreshold:      cfg.Duplicates.LocalThreshold,
+		DomainThreshold:     cfg.Duplicates.DomainThreshold,
+	}
+	return settings, settings.Validate()
+}
+
+// newRouter creates the router, sets up middleware, and establishes routes
+// and handlers for the people provided by source. People's first names are
+// matched with nicknameDictionary, and review decisions about possible
+// duplicates are kept in decisionStore.
+func newRouter(source slapi.PeopleSource, healthHandler *health.Handler, cfg *config.Config, nicknameDictionary *nicknames.Dictionary, decisionStore *decisions.Store) chi.Router {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
 	r.Use(middleware.Logger)
+	r.Use(metrics.Instrument)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.URLFormat)
 	r.Use(render.SetContentType(render.ContentTypeJSON))
 
-	slapi.InitializeClient(*apikey, salesLoftApiURL)
+	r.Get("/healthz", healthHandler.LivenessHandler)
+	r.Get("/readyz", healthHandler.ReadinessHandler)
+	r.Method("GET", "/metrics", metrics.Handler())
+
+	peopleHandler := slapi.NewHandler(source)
+	charsHandler := chars.NewHandler(source, cfg.Characters.BlackListSet())
+	// The settings were checked when the configuration was loaded.
+	dupesSettings, _ := duplicatesSettings(cfg)
+	dupesHandler := dupes.NewHandler(source, dupesSettings)
+	dupesHandler.SetPersonMatching(dupes.PersonWeights{
+		Email:    cfg.Duplicates.People.EmailWeight,
+		Name:     cfg.Duplicates.People.NameWeight,
+		Phonetic: cfg.Duplicates.People.PhoneticWeight,
+		Title:    cfg.Duplicates.People.TitleWeight,
+	}, cfg.Duplicates.People.Threshold)
+	dupesHandler.SetNicknames(nicknameDictionary)
+	dupesHandler.SetDecisions(decisionStore)
+	domainsHandler := domains.NewHandler(source)
 	r.Route("/people", func(r chi.Router) {
-		r.Get("/", slapi.ListPeopleHandler)
-		r.Get("/emails/char-frequencies", chars.EmailCharacterFrequenciesHandler)
-		r.Get("/emails/duplicates", dupes.PossibleDuplicateEmailsHandler)
+		r.Get("/", peopleHandler.ListPeopleHandler)
+		r.Get("/duplicates", dupesHandler.PossibleDuplicatePeopleHandler)
+		r.Post("/duplicates/{clusterId}/decision", dupesHandler.DecisionHandler)
+		r.Get("/emails/char-frequencies", charsHandler.EmailCharacterFrequenciesHandler)
+		r.Get("/emails/duplicates", dupesHandler.PossibleDuplicateEmailsHandler)
+		r.Get("/emails/duplicates/check", dupesHandler.CheckEmailHandler)
+		r.Get("/emails/domain-typos", domainsHandler.DomainTyposHandler)
 	})
 
 	// Add file serving for the site's main page and other static assets.
@@ -67,6 +212,5 @@ func main() {
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "index.html")
 	})
-
-	http.ListenAndServe(":"+*port, r)
+	return r
 }
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/google/go-cmp/cmp"
+	chars "github.com/slpeople/characters"
+	config "github.com/slpeople/config"
+	decisions "github.com/slpeople/decisions"
+	domains "github.com/slpeople/domains"
+	dupes "github.com/slpeople/duplicates"
+	health "github.com/slpeople/health"
+	nicknames "github.com/slpeople/nicknames"
+	slapi "github.com/slpeople/salesloftapi"
+	"github.com/slpeople/salesloftapi/slmock"
+)
+
+// newTestService starts the service against a stand-in SalesLoft API serving
+// the slmock fixture.
+func newTestService(t *testing.T, options slmock.Options) (*httptest.Server, slapi.People, func()) {
+	people, err := slmock.LoadFixture("salesloftapi/slmock/testdata/people.json")
+	if err != nil {
+		t.Fatalf("unable to load the fixture: %v\n", err)
+	}
+	options.Token = "test-key"
+	api := httptest.NewServer(slmock.NewServer(people, options))
+	client := slapi.NewClient("test-key", api.URL+slmock.PeoplePath, 4)
+	client.SetRetryPolicy(slapi.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	service := httptest.NewServer(newRouter(slapi.NewPeopleCache(client.ListPeopleContext, time.Minute), health.NewHandler(), config.Default(), nicknames.Default(), decisions.NewStore()))
+	return service, people, func() {
+		service.Close()
+		api.Close()
+	}
+}
+
+func getJSON(t *testing.T, url string, v interface{}) *http.Response {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v\n", url, err)
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatalf("GET %s returned an undecodable body: %v\n", url, err)
+	}
+	return resp
+}
+
+func TestPeopleRoutes(t *testing.T) {
+	service, people, cleanup := newTestService(t, slmock.Options{})
+	defer cleanup()
+
+	var listed struct {
+		People slapi.People `json:"people"`
+	}
+	if resp := getJSON(t, service.URL+"/people", &listed); resp.StatusCode != http.StatusOK {
+		t.Fatalf("/people responded %d\n", resp.StatusCode)
+	}
+	if !cmp.Equal(listed.People, people) {
+		t.Fatalf("/people did not list the fixture's people: got %d of %d\n", len(listed.People), len(people))
+	}
+
+	var frequencies struct {
+		Frequencies chars.SortedCharFreqs `json:"frequencies"`
+	}
+	if resp := getJSON(t, service.URL+"/people/emails/char-frequencies", &frequencies); resp.StatusCode != http.StatusOK {
+		t.Fatalf("/people/emails/char-frequencies responded %d\n", resp.StatusCode)
+	}
+	counted := chars.CharacterFrequencies{}
+	for _, kv := range frequencies.Frequencies {
+		counted[kv.Key] = kv.Value
+	}
+	emailAddresses := make([]string, len(people))
+	for i := range people {
+		emailAddresses[i] = people[i].EmailAddress
+	}
+	if expected := chars.CharacterFrequencyCountOfStrings(emailAddresses, map[string]bool{".": true, "@": true}); !cmp.Equal(counted, expected) {
+		t.Fatalf("unexpected character frequencies: \n\tresult: %v\n\texpect: %v\n", counted, expected)
+	}
+
+	var duplicates struct {
+		PossibleDuplicates dupes.PossibleDuplicates `json:"possibleDuplicates"`
+		Clusters           []dupes.ExplainedCluster `json:"clusters"`
+	}
+	if resp := getJSON(t, service.URL+"/people/emails/duplicates", &duplicates); resp.StatusCode != http.StatusOK {
+		t.Fatalf("/people/emails/duplicates responded %d\n", resp.StatusCode)
+	}
+	if expected := (dupes.PossibleDuplicates{{"dan@test.com", "dann@test.com"}}); !cmp.Equal(duplicates.PossibleDuplicates, expected) {
+		t.Fatalf("unexpected duplicates: \n\tresult: %v\n\texpect: %v\n", duplicates.PossibleDuplicates, expected)
+	}
+
+	decisionURL := service.URL + "/people/duplicates/" + duplicates.Clusters[0].ID + "/decision"
+	resp, err := http.Post(decisionURL, "application/json", strings.NewReader(`{"decision": "not_duplicate"}`))
+	if err != nil {
+		t.Fatalf("POST %s failed: %v\n", decisionURL, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("%s responded %d\n", decisionURL, resp.StatusCode)
+	}
+	duplicates.PossibleDuplicates = nil
+	getJSON(t, service.URL+"/people/emails/duplicates", &duplicates)
+	if len(duplicates.PossibleDuplicates) != 0 {
+		t.Fatalf("expected the addresses decided not to be duplicates to be left out: %v\n", duplicates.PossibleDuplicates)
+	}
+
+	var check dupes.EmailCheckResponse
+	if resp := getJSON(t, service.URL+"/people/emails/duplicates/check?email=dann@test.com", &check); resp.StatusCode != http.StatusOK {
+		t.Fatalf("/people/emails/duplicates/check responded %d\n", resp.StatusCode)
+	}
+	if len(check.ExactMatches) != 1 || check.ExactMatches[0].ID != people[1].ID ||
+		len(check.NearMatches) != 1 || check.NearMatches[0].ID != people[0].ID {
+		t.Fatalf("unexpected matches for dann@test.com: %#v\n", check)
+	}
+
+	var personDuplicates dupes.PersonDuplicatesResponse
+	if resp := getJSON(t, service.URL+"/people/duplicates", &personDuplicates); resp.StatusCode != http.StatusOK {
+		t.Fatalf("/people/duplicates responded %d\n", resp.StatusCode)
+	}
+	if len(personDuplicates.Clusters) != 1 || len(personDuplicates.Clusters[0].People) != 2 ||
+		personDuplicates.Clusters[0].People[0].ID != people[0].ID || personDuplicates.Clusters[0].People[1].ID != people[1].ID {
+		t.Fatalf("unexpected person duplicates: %#v\n", personDuplicates)
+	}
+
+	var typos domains.DomainTyposResponse
+	if resp := getJSON(t, service.URL+"/people/emails/domain-typos", &typos); resp.StatusCode != http.StatusOK {
+		t.Fatalf("/people/emails/domain-typos responded %d\n", resp.StatusCode)
+	}
+	// The fixture's domains are too rarely used to be taken as references.
+	if typos.DomainTypos == nil || len(typos.DomainTypos) != 0 {
+		t.Fatalf("expected an empty list of domain typos: %#v\n", typos)
+	}
+}
+
+func TestPeopleRoutesUpstreamFailure(t *testing.T) {
+	for _, route := range []string{"/people", "/people/emails/char-frequencies", "/people/emails/duplicates", "/people/emails/duplicates/check?email=dan@test.com", "/people/emails/domain-typos", "/people/duplicates"} {
+		service, _, cleanup := newTestService(t, slmock.Options{Fault: slmock.FaultServerError, FaultRate: 1})
+		var body struct {
+			Status string `json:"status"`
+		}
+		resp := getJSON(t, service.URL+route, &body)
+		cleanup()
+		if resp.StatusCode != http.StatusServiceUnavailable || body.Status == "" {
+			t.Fatalf("%s: expected a 503 error response, got %d %q\n", route, resp.StatusCode, body.Status)
+		}
+	}
+}
+
+func TestMetricsRoute(t *testing.T) {
+	service, _, cleanup := newTestService(t, slmock.Options{})
+	defer cleanup()
+
+	var duplicates struct{}
+	getJSON(t, service.URL+"/people/emails/duplicates", &duplicates)
+	resp, err := http.Get(service.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics failed: %v\n", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	for _, name := range []string{
+		`http_requests_total{code="200",method="GET",route="/people/emails/duplicates"}`,
+		`http_request_duration_seconds_count{method="GET",route="/people/emails/duplicates"}`,
+		`salesloft_requests_total{code="200"}`,
+		`salesloft_request_duration_seconds_count`,
+		`salesloft_list_people_pages_count`,
+		`people_cache_requests_total{result="miss"}`,
+		`duplicates_detection_duration_seconds_count`,
+		`duplicates_candidate_pairs_compared_count`,
+	} {
+		if !strings.Contains(string(body), "\n"+name+" ") {
+			t.Fatalf("expected /metrics to report %s: \n%s\n", name, body)
+		}
+	}
+}
+
+func TestServeDrainsOnSignal(t *testing.T) {
+	started := make(chan struct{})
+	router := chi.NewRouter()
+	router.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		fmt.Fprint(w, "done")
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v\n", err)
+	}
+	healthHandler := health.NewHandler()
+	stop := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(&http.Server{Handler: router}, listener, healthHandler, time.Second, stop)
+	}()
+
+	responses := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String() + "/slow")
+		if err != nil {
+			responses <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		responses <- string(body)
+	}()
+	<-started
+	stop <- syscall.SIGTERM
+
+	if err := <-served; err != nil {
+		t.Fatalf("expected a clean shutdown, got %v\n", err)
+	}
+	if body := <-responses; body != "done" {
+		t.Fatalf("expected the in-flight request to finish, got %q\n", body)
+	}
+	w := httptest.NewRecorder()
+	healthHandler.ReadinessHandler(w, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected readiness to fail after shutdown, got %d\n", w.Code)
+	}
+}
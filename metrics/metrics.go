@@ -0,0 +1,15 @@
+// Package metrics serves the Prometheus metrics that the service's packages
+// register with the default registry, and instruments the router's
+// requests.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Handler serves the metrics in the default Prometheus registry.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
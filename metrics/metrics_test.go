@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+)
+
+func TestInstrumentLabelsRoutePatterns(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Instrument)
+	r.Route("/people", func(r chi.Router) {
+		r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		})
+	})
+	r.Method("GET", "/metrics", Handler())
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	for _, path := range []string{"/people/1", "/people/2", "/nowhere"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s failed: %v\n", path, err)
+		}
+		resp.Body.Close()
+	}
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics failed: %v\n", err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Fatalf("unexpected content type %q\n", contentType)
+	}
+	for _, line := range []string{
+		`http_requests_total{code="418",method="GET",route="/people/{id}"} 2`,
+		`http_requests_total{code="404",method="GET",route="unmatched"} 1`,
+		`http_request_duration_seconds_count{method="GET",route="/people/{id}"} 2`,
+	} {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Fatalf("expected the exposition to contain %q: \n%s\n", line, body)
+		}
+	}
+}
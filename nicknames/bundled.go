@@ -0,0 +1,97 @@
+package nicknames
+
+// bundled lists common English first names, each followed by its nicknames and
+// diminutives.
+var bundled = [][]string{
+	{"abigail", "abby", "abbie", "gail"},
+	{"abraham", "abe", "bram"},
+	{"albert", "al", "bert", "bertie"},
+	{"alexander", "alex", "al", "xander", "sandy", "lex"},
+	{"alexandra", "alex", "alexa", "lexi", "sandra", "sandy"},
+	{"alfred", "al", "alf", "alfie", "fred", "freddie"},
+	{"alan", "al"},
+	{"allison", "ali", "allie"},
+	{"andrew", "andy", "drew"},
+	{"angela", "angie"},
+	{"anthony", "tony", "ant"},
+	{"antonio", "tony"},
+	{"arthur", "art", "artie"},
+	{"barbara", "barb", "barbie", "babs"},
+	{"benjamin", "ben", "benny", "benji"},
+	{"bernard", "bernie"},
+	{"beverly", "bev"},
+	{"bradley", "brad"},
+	{"catherine", "cathy", "cat", "kate", "katie", "kit"},
+	{"charles", "charlie", "chuck", "chas", "chaz"},
+	{"charlotte", "charlie", "lottie", "char"},
+	{"christina", "chris", "christy", "tina"},
+	{"christine", "chris", "christy", "tina"},
+	{"christopher", "chris", "kit", "topher"},
+	{"cynthia", "cindy"},
+	{"daniel", "dan", "danny"},
+	{"david", "dave", "davey"},
+	{"deborah", "deb", "debbie", "debby"},
+	{"donald", "don", "donnie"},
+	{"dorothy", "dot", "dottie", "dolly"},
+	{"douglas", "doug"},
+	{"edward", "ed", "eddie", "ned", "ted", "teddy"},
+	{"edwin", "ed", "eddie"},
+	{"eleanor", "ellie", "nell", "nora"},
+	{"elizabeth", "liz", "lizzie", "beth", "betty", "betsy", "eliza", "libby", "lisa", "liza", "bess"},
+	{"emily", "em", "emmy"},
+	{"eugene", "gene"},
+	{"frances", "fran", "frankie", "fanny"},
+	{"francis", "frank", "frankie"},
+	{"frank", "frankie"},
+	{"frederick", "fred", "freddie", "freddy", "rick"},
+	{"gabriel", "gabe"},
+	{"gerald", "gerry", "jerry"},
+	{"gregory", "greg"},
+	{"harold", "harry", "hal"},
+	{"henry", "harry", "hank", "hal"},
+	{"jacob", "jake"},
+	{"james", "jim", "jimmy", "jamie"},
+	{"janet", "jan"},
+	{"jeffrey", "jeff"},
+	{"jennifer", "jen", "jenny", "jenn"},
+	{"jessica", "jess", "jessie"},
+	{"john", "jack", "johnny", "jon"},
+	{"jonathan", "jon", "jonny", "nathan"},
+	{"joseph", "joe", "joey"},
+	{"joshua", "josh"},
+	{"judith", "judy"},
+	{"katherine", "kathy", "kate", "katie", "kat", "kit", "kay"},
+	{"kathleen", "kathy", "kate", "katie"},
+	{"kenneth", "ken", "kenny"},
+	{"lawrence", "larry", "laurie"},
+	{"leonard", "leo", "len", "lenny"},
+	{"louis", "lou", "louie"},
+	{"margaret", "maggie", "meg", "peggy", "marge", "margie", "greta", "daisy"},
+	{"matthew", "matt", "matty"},
+	{"michael", "mike", "mikey", "mick", "mickey"},
+	{"nathaniel", "nate", "nat", "nathan"},
+	{"nicholas", "nick", "nicky", "nico"},
+	{"patricia", "pat", "patty", "trish", "tricia"},
+	{"patrick", "pat", "paddy", "rick"},
+	{"peter", "pete"},
+	{"philip", "phil", "pip"},
+	{"rebecca", "becky", "becca"},
+	{"richard", "rick", "ricky", "rich", "richie", "dick"},
+	{"robert", "bob", "bobby", "rob", "robbie", "bert"},
+	{"ronald", "ron", "ronnie"},
+	{"samantha", "sam", "sammy"},
+	{"samuel", "sam", "sammy"},
+	{"sandra", "sandy"},
+	{"stephanie", "steph", "stephie"},
+	{"stephen", "steve", "stevie"},
+	{"steven", "steve", "stevie"},
+	{"susan", "sue", "susie", "suzy"},
+	{"theodore", "ted", "teddy", "theo"},
+	{"thomas", "tom", "tommy"},
+	{"timothy", "tim", "timmy"},
+	{"victoria", "vicky", "tori"},
+	{"vincent", "vince", "vinny"},
+	{"walter", "walt", "wally"},
+	{"william", "bill", "billy", "will", "willie", "liam"},
+	{"zachary", "zach", "zack"},
+}
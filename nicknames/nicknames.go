@@ -0,0 +1,129 @@
+// Package nicknames recognises the nicknames and diminutives of first names,
+// such as Bob for Robert and Liz for Elizabeth, so that people who go by
+// either can be matched.
+package nicknames
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type (
+	// Dictionary groups first names with their nicknames. Each group has a
+	// formal name, such as robert, and the names that stand for it, such as
+	// bob, bobby and rob. A name may be in several groups, as al is for
+	// albert, alfred and alan. A Dictionary is not modified after it is built
+	// and is safe for concurrent use.
+	Dictionary struct {
+		groups map[string][]string
+	}
+)
+
+// NewDictionary builds a Dictionary from groups, each listing a formal name
+// followed by its nicknames. Names are compared case-insensitively.
+func NewDictionary(groups [][]string) *Dictionary {
+	d := &Dictionary{groups: make(map[string][]string)}
+	for _, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		formal := normalise(group[0])
+		for _, name := range group {
+			d.add(normalise(name), formal)
+		}
+	}
+	return d
+}
+
+var bundledDictionary = NewDictionary(bundled)
+
+// Default returns the bundled Dictionary of common English first names.
+func Default() *Dictionary {
+	return bundledDictionary
+}
+
+// Load reads a Dictionary from the file at path, which replaces the bundled
+// one. See Read for the format.
+func Load(path string) (*Dictionary, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read nicknames from %s: %v", path, err)
+	}
+	return d, nil
+}
+
+// Read reads a Dictionary with a group on each line: the formal name followed
+// by its nicknames, separated by commas, as in
+//
+//	robert, bob, bobby, rob, robbie
+//
+// Blank lines and lines starting with # are skipped.
+func Read(r io.Reader) (*Dictionary, error) {
+	var groups [][]string
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		var group []string
+		for _, name := range strings.Split(text, ",") {
+			if name = normalise(name); name == "" {
+				return nil, fmt.Errorf("line %d: empty name", line)
+			}
+			group = append(group, name)
+		}
+		groups = append(groups, group)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewDictionary(groups), nil
+}
+
+// Formal returns the formal names that name stands for, in the order their
+// groups were given. A formal name stands for itself. A name outside the
+// dictionary has none.
+func (d *Dictionary) Formal(name string) []string {
+	return d.groups[normalise(name)]
+}
+
+// Equivalent reports whether a and b are the same first name, ignoring case,
+// or can stand for the same formal name, as Bob and Robert, or Bob and Rob,
+// can.
+func (d *Dictionary) Equivalent(a, b string) bool {
+	a, b = normalise(a), normalise(b)
+	if a == b {
+		return a != ""
+	}
+	for _, formalA := range d.groups[a] {
+		for _, formalB := range d.groups[b] {
+			if formalA == formalB {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// add records that name stands for formal, once.
+func (d *Dictionary) add(name, formal string) {
+	for _, existing := range d.groups[name] {
+		if existing == formal {
+			return
+		}
+	}
+	d.groups[name] = append(d.groups[name], formal)
+}
+
+func normalise(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
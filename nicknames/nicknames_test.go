@@ -0,0 +1,83 @@
+package nicknames
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEquivalent(t *testing.T) {
+	equivalentTestData := []struct {
+		a, b     string
+		expected bool
+	}{
+		{a: "Bob", b: "Robert", expected: true},
+		{a: "robert", b: "BOB", expected: true},
+		{a: "Bob", b: "Rob", expected: true},
+		{a: "Liz", b: "Elizabeth", expected: true},
+		{a: "Beth", b: "Liz", expected: true},
+		{a: "Bill", b: "William", expected: true},
+		{a: "Kate", b: "Katherine", expected: true},
+		{a: "Kate", b: "Catherine", expected: true},
+		{a: "Al", b: "Alfred", expected: true},
+		{a: "Al", b: "Albert", expected: true},
+		// Al stands for both, but Alfred and Albert are different names.
+		{a: "Alfred", b: "Albert", expected: false},
+		{a: "Zelda", b: "Zelda", expected: true},
+		{a: "Bob", b: "William", expected: false},
+		{a: "", b: "", expected: false},
+	}
+	for _, td := range equivalentTestData {
+		if result := Default().Equivalent(td.a, td.b); result != td.expected {
+			t.Fatalf("unexpected equivalence of %q and %q: \n\tresult: %v\n\texpect: %v\n", td.a, td.b, result, td.expected)
+		}
+	}
+}
+
+func TestFormal(t *testing.T) {
+	formalTestData := []struct {
+		name     string
+		expected []string
+	}{
+		{name: "Bob", expected: []string{"robert"}},
+		{name: "robert", expected: []string{"robert"}},
+		{name: "al", expected: []string{"albert", "alexander", "alfred", "alan"}},
+		{name: "zelda", expected: nil},
+	}
+	for _, td := range formalTestData {
+		if result := Default().Formal(td.name); !cmp.Equal(result, td.expected) {
+			t.Fatalf("unexpected formal names of %q: \n\tresult: %v\n\texpect: %v\n", td.name, result, td.expected)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	d, err := Read(strings.NewReader("# Names\nRobert, Bob\n\n  elizabeth,liz , beth\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	if !d.Equivalent("bob", "Robert") || !d.Equivalent("Liz", "Beth") {
+		t.Fatalf("read nicknames are not equivalent\n")
+	}
+	// The file replaces the bundled dictionary.
+	if d.Equivalent("bill", "william") {
+		t.Fatalf("unexpected equivalence of bill and william\n")
+	}
+	if _, err := Read(strings.NewReader("robert,,bob\n")); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("unexpected error for an empty name: %v\n", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	d, err := Load("testdata/nicknames.txt")
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	if !d.Equivalent("Peggy", "Maggie") {
+		t.Fatalf("loaded nicknames are not equivalent\n")
+	}
+	if _, err := Load("testdata/missing.txt"); err == nil {
+		t.Fatalf("expected an error for a missing file\n")
+	}
+}
@@ -0,0 +1,573 @@
+package phonetic
+
+import (
+	"strings"
+)
+
+// metaphoneLength is the length Double Metaphone codes are truncated to.
+const metaphoneLength = 4
+
+type (
+	// metaphone holds the state of a Double Metaphone encoding: the name
+	// being encoded and the primary and alternate codes built so far.
+	metaphone struct {
+		value              []rune
+		slavoGermanic      bool
+		primary, alternate []rune
+	}
+)
+
+// DoubleMetaphone returns the primary and alternate Double Metaphone codes of
+// name, as in SM0 and XMT for both Smith and Smyth, after Lawrence Philips'
+// algorithm. The primary code follows the most common English pronunciation
+// and the alternate allows for the name's likely origin, such as Germanic or
+// Slavic; the two are equal when only one pronunciation is likely. Names
+// match when either of their codes are equal. A name without letters has
+// empty codes.
+func DoubleMetaphone(name string) (primary, alternate string) {
+	value := []rune(strings.ToUpper(strings.TrimSpace(name)))
+	if len(value) == 0 {
+		return "", ""
+	}
+	m := &metaphone{value: value}
+	m.slavoGermanic = m.isSlavoGermanic()
+	index := 0
+	for _, silent := range []string{"GN", "KN", "PN", "WR", "PS"} {
+		if strings.HasPrefix(string(value), silent) {
+			index = 1
+		}
+	}
+	for !m.complete() && index < len(value) {
+		switch value[index] {
+		case 'A', 'E', 'I', 'O', 'U', 'Y':
+			if index == 0 {
+				m.add("A")
+			}
+			index++
+		case 'B':
+			m.add("P")
+			index = m.skipDouble(index, 'B')
+		case 'Ç':
+			m.add("S")
+			index++
+		case 'C':
+			index = m.c(index)
+		case 'D':
+			index = m.d(index)
+		case 'F':
+			m.add("F")
+			index = m.skipDouble(index, 'F')
+		case 'G':
+			index = m.g(index)
+		case 'H':
+			index = m.h(index)
+		case 'J':
+			index = m.j(index)
+		case 'K':
+			m.add("K")
+			index = m.skipDouble(index, 'K')
+		case 'L':
+			index = m.l(index)
+		case 'M':
+			m.add("M")
+			if m.at(index+1) == 'M' || (m.contains(index-1, "UMB") && (index+1 == len(value)-1 || m.contains(index+2, "ER"))) {
+				index += 2
+			} else {
+				index++
+			}
+		case 'N':
+			m.add("N")
+			index = m.skipDouble(index, 'N')
+		case 'Ñ':
+			m.add("N")
+			index++
+		case 'P':
+			if m.at(index+1) == 'H' {
+				m.add("F")
+				index += 2
+			} else {
+				m.add("P")
+				if m.contains(index+1, "P", "B") {
+					index += 2
+				} else {
+					index++
+				}
+			}
+		case 'Q':
+			m.add("K")
+			index = m.skipDouble(index, 'Q')
+		case 'R':
+			index = m.r(index)
+		case 'S':
+			index = m.s(index)
+		case 'T':
+			index = m.t(index)
+		case 'V':
+			m.add("F")
+			index = m.skipDouble(index, 'V')
+		case 'W':
+			index = m.w(index)
+		case 'X':
+			index = m.x(index)
+		case 'Z':
+			index = m.z(index)
+		default:
+			index++
+		}
+	}
+	return string(m.primary), string(m.alternate)
+}
+
+func (m *metaphone) c(index int) int {
+	switch {
+	case m.germanicCH(index):
+		// Various Germanic, as in Bacher and Macher.
+		m.add("K")
+		return index + 2
+	case index == 0 && m.contains(index, "CAESAR"):
+		m.add("S")
+		return index + 2
+	case m.contains(index, "CH"):
+		return m.ch(index)
+	case m.contains(index, "CZ") && !m.contains(index-2, "WICZ"):
+		// Czerny.
+		m.addBoth("S", "X")
+		return index + 2
+	case m.contains(index+1, "CIA"):
+		// Focaccia.
+		m.add("X")
+		return index + 3
+	case m.contains(index, "CC") && !(index == 1 && m.at(0) == 'M'):
+		// A double C, but not McClelland.
+		if m.contains(index+2, "I", "E", "H") && !m.contains(index+2, "HU") {
+			// Bellocchio, but not Bacchus.
+			if (index == 1 && m.at(index-1) == 'A') || m.contains(index-1, "UCCEE", "UCCES") {
+				// Accident, accede and succeed.
+				m.add("KS")
+			} else {
+				// Bacci, Bertucci and other Italian.
+				m.add("X")
+			}
+			return index + 3
+		}
+		m.add("K")
+		return index + 2
+	case m.contains(index, "CK", "CG", "CQ"):
+		m.add("K")
+		return index + 2
+	case m.contains(index, "CI", "CE", "CY"):
+		// Italian against English.
+		if m.contains(index, "CIO", "CIE", "CIA") {
+			m.addBoth("S", "X")
+		} else {
+			m.add("S")
+		}
+		return index + 2
+	}
+	m.add("K")
+	switch {
+	case m.contains(index+1, " C", " Q", " G"):
+		// Mac Caffrey and Mac Gregor.
+		return index + 3
+	case m.contains(index+1, "C", "K", "Q") && !m.contains(index+1, "CE", "CI"):
+		return index + 2
+	}
+	return index + 1
+}
+
+// germanicCH reports whether the C at index, as in Bacher, is a Germanic CH.
+func (m *metaphone) germanicCH(index int) bool {
+	switch {
+	case m.contains(index, "CHIA"):
+		return true
+	case index <= 1 || m.isVowel(index-2) || !m.contains(index-1, "ACH"):
+		return false
+	}
+	next := m.at(index + 2)
+	return (next != 'I' && next != 'E') || m.contains(index-2, "BACHER", "MACHER")
+}
+
+func (m *metaphone) ch(index int) int {
+	switch {
+	case index > 0 && m.contains(index, "CHAE"):
+		// Michael.
+		m.addBoth("K", "X")
+	case index == 0 && (m.contains(index+1, "HARAC", "HARIS") || m.contains(index+1, "HOR", "HYM", "HIA", "HEM")) && !m.contains(0, "CHORE"):
+		// Greek roots, as in chemistry and chorus.
+		m.add("K")
+	case m.contains(0, "VAN ", "VON ", "SCH") || m.contains(index-2, "ORCHES", "ARCHIT", "ORCHID") || m.contains(index+2, "T", "S") ||
+		((m.contains(index-1, "A", "O", "U", "E") || index == 0) && (m.contains(index+2, "L", "R", "N", "M", "B", "H", "F", "V", "W", " ") || index+1 == len(m.value)-1)):
+		// Germanic, Greek or otherwise a CH for a KH sound.
+		m.add("K")
+	case index > 0 && m.contains(0, "MC"):
+		m.add("K")
+	case index > 0:
+		m.addBoth("X", "K")
+	default:
+		m.add("X")
+	}
+	return index + 2
+}
+
+func (m *metaphone) d(index int) int {
+	switch {
+	case m.contains(index, "DG"):
+		if m.contains(index+2, "I", "E", "Y") {
+			// Edge.
+			m.add("J")
+			return index + 3
+		}
+		// Edgar.
+		m.add("TK")
+		return index + 2
+	case m.contains(index, "DT", "DD"):
+		m.add("T")
+		return index + 2
+	}
+	m.add("T")
+	return index + 1
+}
+
+func (m *metaphone) g(index int) int {
+	switch {
+	case m.at(index+1) == 'H':
+		return m.gh(index)
+	case m.at(index+1) == 'N':
+		switch {
+		case index == 1 && m.isVowel(0) && !m.slavoGermanic:
+			m.addBoth("KN", "N")
+		case !m.contains(index+2, "EY") && m.at(index+1) != 'Y' && !m.slavoGermanic:
+			m.addBoth("N", "KN")
+		default:
+			m.add("KN")
+		}
+		return index + 2
+	case m.contains(index+1, "LI") && !m.slavoGermanic:
+		// Tagliaro.
+		m.addBoth("KL", "L")
+		return index + 2
+	case index == 0 && (m.at(index+1) == 'Y' || m.contains(index+1, "ES", "EP", "EB", "EL", "EY", "IB", "IL", "IN", "IE", "EI", "ER")):
+		// -ges-, -gep-, -gel- and -gie- at the start.
+		m.addBoth("K", "J")
+		return index + 2
+	case (m.contains(index+1, "ER") || m.at(index+1) == 'Y') && !m.contains(0, "DANGER", "RANGER", "MANGER") &&
+		!m.contains(index-1, "E", "I") && !m.contains(index-1, "RGY", "OGY"):
+		// -ger- and -gy-.
+		m.addBoth("K", "J")
+		return index + 2
+	case m.contains(index+1, "E", "I", "Y") || m.contains(index-1, "AGGI", "OGGI"):
+		// Italian, as in Biaggi.
+		switch {
+		case m.contains(0, "VAN ", "VON ", "SCH") || m.contains(index+1, "ET"):
+			// Obviously Germanic.
+			m.add("K")
+		case m.contains(index+1, "IER"):
+			m.add("J")
+		default:
+			m.addBoth("J", "K")
+		}
+		return index + 2
+	case m.at(index+1) == 'G':
+		m.add("K")
+		return index + 2
+	}
+	m.add("K")
+	return index + 1
+}
+
+func (m *metaphone) gh(index int) int {
+	switch {
+	case index > 0 && !m.isVowel(index-1):
+		m.add("K")
+	case index == 0:
+		// Ghislane and Ghiradelli.
+		if m.at(index+2) == 'I' {
+			m.add("J")
+		} else {
+			m.add("K")
+		}
+	case (index > 1 && m.contains(index-2, "B", "H", "D")) || (index > 2 && m.contains(index-3, "B", "H", "D")) || (index > 3 && m.contains(index-4, "B", "H")):
+		// Parker's rule, as in Hugh.
+	case index > 2 && m.at(index-1) == 'U' && m.contains(index-3, "C", "G", "L", "R", "T"):
+		// Laugh, McLaughlin, cough, gough, rough and tough.
+		m.add("F")
+	case m.at(index-1) != 'I':
+		m.add("K")
+	}
+	return index + 2
+}
+
+func (m *metaphone) h(index int) int {
+	// Only kept when first and before a vowel, or between two vowels.
+	if (index == 0 || m.isVowel(index-1)) && m.isVowel(index+1) {
+		m.add("H")
+		return index + 2
+	}
+	return index + 1
+}
+
+func (m *metaphone) j(index int) int {
+	if m.contains(index, "JOSE") || m.contains(0, "SAN ") {
+		// Obviously Spanish, as in Jose and San Jacinto.
+		if (index == 0 && (m.at(index+4) == ' ' || len(m.value) == 4)) || m.contains(0, "SAN ") {
+			m.add("H")
+		} else {
+			m.addBoth("J", "H")
+		}
+		return index + 1
+	}
+	switch {
+	case index == 0:
+		// Yankelovich and Jankelowicz.
+		m.addBoth("J", "A")
+	case m.isVowel(index-1) && !m.slavoGermanic && (m.at(index+1) == 'A' || m.at(index+1) == 'O'):
+		// Spanish pronunciation, as in Bajador.
+		m.addBoth("J", "H")
+	case index == len(m.value)-1:
+		m.addBoth("J", "")
+	case !m.contains(index+1, "L", "T", "K", "S", "N", "M", "B", "Z") && !m.contains(index-1, "S", "K", "L"):
+		m.add("J")
+	}
+	return m.skipDouble(index, 'J')
+}
+
+func (m *metaphone) l(index int) int {
+	if m.at(index+1) != 'L' {
+		m.add("L")
+		return index + 1
+	}
+	last := len(m.value) - 1
+	// Spanish, as in Cabrillo and Gallegos, where the LL is silent in the
+	// alternate pronunciation.
+	if (index == last-2 && m.contains(index-1, "ILLO", "ILLA", "ALLE")) ||
+		((m.contains(last-1, "AS", "OS") || m.contains(last, "A", "O")) && m.contains(index-1, "ALLE")) {
+		m.addBoth("L", "")
+	} else {
+		m.add("L")
+	}
+	return index + 2
+}
+
+func (m *metaphone) r(index int) int {
+	// French, as in Rogier, where the final R is silent.
+	if index == len(m.value)-1 && !m.slavoGermanic && m.contains(index-2, "IE") && !m.contains(index-4, "ME", "MA") {
+		m.addBoth("", "R")
+	} else {
+		m.add("R")
+	}
+	return m.skipDouble(index, 'R')
+}
+
+func (m *metaphone) s(index int) int {
+	switch {
+	case m.contains(index-1, "ISL", "YSL"):
+		// Island, isle, Carlisle and Carlysle.
+		return index + 1
+	case index == 0 && m.contains(index, "SUGAR"):
+		m.addBoth("X", "S")
+		return index + 1
+	case m.contains(index, "SH"):
+		if m.contains(index+1, "HEIM", "HOEK", "HOLM", "HOLZ") {
+			// Germanic.
+			m.add("S")
+		} else {
+			m.add("X")
+		}
+		return index + 2
+	case m.contains(index, "SIO", "SIA") || m.contains(index, "SIAN"):
+		// Italian and Armenian.
+		if m.slavoGermanic {
+			m.add("S")
+		} else {
+			m.addBoth("S", "X")
+		}
+		return index + 3
+	case (index == 0 && m.contains(index+1, "M", "N", "L", "W")) || m.contains(index+1, "Z"):
+		// German and anglicisations, as in Smith for Schmidt and Snider for
+		// Schneider, and Slavic SZ.
+		m.addBoth("S", "X")
+		if m.contains(index+1, "Z") {
+			return index + 2
+		}
+		return index + 1
+	case m.contains(index, "SC"):
+		return m.sc(index)
+	}
+	if index == len(m.value)-1 && m.contains(index-2, "AI", "OI") {
+		// French, as in Resnais and Artois.
+		m.addBoth("", "S")
+	} else {
+		m.add("S")
+	}
+	if m.contains(index+1, "S", "Z") {
+		return index + 2
+	}
+	return index + 1
+}
+
+func (m *metaphone) sc(index int) int {
+	switch {
+	case m.at(index+2) == 'H':
+		// Schlesinger's rule.
+		switch {
+		case m.contains(index+3, "ER", "EN"):
+			// Dutch, as in Schermerhorn and Schenker.
+			m.addBoth("X", "SK")
+		case m.contains(index+3, "OO", "UY", "ED", "EM"):
+			// Dutch, as in school and schooner.
+			m.add("SK")
+		case index == 0 && !m.isVowel(3) && m.at(3) != 'W':
+			m.addBoth("X", "S")
+		default:
+			m.add("X")
+		}
+	case m.contains(index+2, "I", "E", "Y"):
+		m.add("S")
+	default:
+		m.add("SK")
+	}
+	return index + 3
+}
+
+func (m *metaphone) t(index int) int {
+	switch {
+	case m.contains(index, "TION"), m.contains(index, "TIA", "TCH"):
+		m.add("X")
+		return index + 3
+	case m.contains(index, "TH") || m.contains(index, "TTH"):
+		if m.contains(index+2, "OM", "AM") || m.contains(0, "VAN ", "VON ", "SCH") {
+			// Thomas, Thames or Germanic.
+			m.add("T")
+		} else {
+			m.addBoth("0", "T")
+		}
+		return index + 2
+	}
+	m.add("T")
+	if m.contains(index+1, "T", "D") {
+		return index + 2
+	}
+	return index + 1
+}
+
+func (m *metaphone) w(index int) int {
+	switch {
+	case m.contains(index, "WR"):
+		m.add("R")
+		return index + 2
+	case index == 0 && m.isVowel(index+1):
+		// Wasserman should match Vasserman.
+		m.addBoth("A", "F")
+		return index + 1
+	case index == 0 && m.contains(index, "WH"):
+		// Uomo should match Womo.
+		m.add("A")
+		return index + 1
+	case (index == len(m.value)-1 && m.isVowel(index-1)) || m.contains(index-1, "EWSKI", "EWSKY", "OWSKI", "OWSKY") || m.contains(0, "SCH"):
+		// Arnow should match Arnoff.
+		m.addBoth("", "F")
+		return index + 1
+	case m.contains(index, "WICZ", "WITZ"):
+		// Polish, as in Filipowicz.
+		m.addBoth("TS", "FX")
+		return index + 4
+	}
+	return index + 1
+}
+
+func (m *metaphone) x(index int) int {
+	if index == 0 {
+		m.add("S")
+		return index + 1
+	}
+	// French, as in Breaux, where the final X is silent.
+	if !(index == len(m.value)-1 && (m.contains(index-3, "IAU", "EAU") || m.contains(index-2, "AU", "OU"))) {
+		m.add("KS")
+	}
+	if m.contains(index+1, "C", "X") {
+		return index + 2
+	}
+	return index + 1
+}
+
+func (m *metaphone) z(index int) int {
+	if m.at(index+1) == 'H' {
+		// Chinese pinyin, as in Zhao.
+		m.add("J")
+		return index + 2
+	}
+	if m.contains(index+1, "ZO", "ZI", "ZA") || (m.slavoGermanic && index > 0 && m.at(index-1) != 'T') {
+		m.addBoth("S", "TS")
+	} else {
+		m.add("S")
+	}
+	return m.skipDouble(index, 'Z')
+}
+
+// add appends code to both the primary and the alternate code.
+func (m *metaphone) add(code string) {
+	m.addBoth(code, code)
+}
+
+// addBoth appends primary to the primary code and alternate to the alternate
+// code, each up to metaphoneLength.
+func (m *metaphone) addBoth(primary, alternate string) {
+	m.primary = appendUpTo(m.primary, primary)
+	m.alternate = appendUpTo(m.alternate, alternate)
+}
+
+func appendUpTo(code []rune, s string) []rune {
+	for _, r := range s {
+		if len(code) == metaphoneLength {
+			break
+		}
+		code = append(code, r)
+	}
+	return code
+}
+
+func (m *metaphone) complete() bool {
+	return len(m.primary) >= metaphoneLength && len(m.alternate) >= metaphoneLength
+}
+
+// at returns the character at index, or 0 outside the name.
+func (m *metaphone) at(index int) rune {
+	if index < 0 || index >= len(m.value) {
+		return 0
+	}
+	return m.value[index]
+}
+
+// contains reports whether any of candidates, which all have the length of
+// the first, occurs in the name at start.
+func (m *metaphone) contains(start int, candidates ...string) bool {
+	length := len([]rune(candidates[0]))
+	if start < 0 || start+length > len(m.value) {
+		return false
+	}
+	target := string(m.value[start : start+length])
+	for _, candidate := range candidates {
+		if target == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *metaphone) isVowel(index int) bool {
+	return m.at(index) != 0 && strings.ContainsRune("AEIOUY", m.at(index))
+}
+
+// skipDouble returns the index after the letter at index, skipping a
+// following repeat of letter.
+func (m *metaphone) skipDouble(index int, letter rune) int {
+	if m.at(index+1) == letter {
+		return index + 2
+	}
+	return index + 1
+}
+
+func (m *metaphone) isSlavoGermanic() bool {
+	value := string(m.value)
+	return strings.ContainsAny(value, "WK") || strings.Contains(value, "CZ") || strings.Contains(value, "WITZ")
+}
@@ -0,0 +1,100 @@
+package phonetic
+
+import (
+	"strings"
+)
+
+// nysiisLength is the length NYSIIS codes are truncated to, as in the
+// original system.
+const nysiisLength = 6
+
+// NYSIIS returns the code of name under the New York State Identification and
+// Intelligence System, which keeps more of a name than Soundex and maps
+// common spelling variants together, as in CATARA for both Katherine and
+// Catherine. Characters other than the letters A to Z are ignored, and a name
+// without any has the empty code.
+func NYSIIS(name string) string {
+	word := string(asciiLetters(name))
+	if word == "" {
+		return ""
+	}
+	for _, prefix := range [][2]string{{"MAC", "MCC"}, {"KN", "NN"}, {"K", "C"}, {"PH", "FF"}, {"PF", "FF"}, {"SCH", "SSS"}} {
+		if strings.HasPrefix(word, prefix[0]) {
+			word = prefix[1] + word[len(prefix[0]):]
+			break
+		}
+	}
+	for _, suffix := range [][2]string{{"EE", "Y"}, {"IE", "Y"}, {"DT", "D"}, {"RT", "D"}, {"RD", "D"}, {"NT", "D"}, {"ND", "D"}} {
+		if strings.HasSuffix(word, suffix[0]) {
+			word = word[:len(word)-len(suffix[0])] + suffix[1]
+			break
+		}
+	}
+
+	// Each letter after the first is translated in place, so that later
+	// letters see the translations before them, and is added to the key
+	// unless it repeats the letter before it.
+	letters := []byte(word)
+	key := []byte{letters[0]}
+	for i := 1; i < len(letters); i++ {
+		copy(letters[i:], nysiisTranslation(letters, i))
+		if letters[i] != letters[i-1] {
+			key = append(key, letters[i])
+		}
+	}
+
+	if len(key) > 1 && key[len(key)-1] == 'S' {
+		key = key[:len(key)-1]
+	}
+	if len(key) > 2 && key[len(key)-2] == 'A' && key[len(key)-1] == 'Y' {
+		key = append(key[:len(key)-2], 'Y')
+	}
+	if len(key) > 1 && key[len(key)-1] == 'A' {
+		key = key[:len(key)-1]
+	}
+	if len(key) > nysiisLength {
+		key = key[:nysiisLength]
+	}
+	return string(key)
+}
+
+// nysiisTranslation returns the letters that replace those starting at
+// letters[i]. A translation of several letters replaces as many.
+func nysiisTranslation(letters []byte, i int) []byte {
+	prev, curr, next, afterNext := letters[i-1], letters[i], byte(0), byte(0)
+	if i+1 < len(letters) {
+		next = letters[i+1]
+	}
+	if i+2 < len(letters) {
+		afterNext = letters[i+2]
+	}
+	switch {
+	case curr == 'E' && next == 'V':
+		return []byte("AF")
+	case isVowel(curr):
+		return []byte("A")
+	case curr == 'Q':
+		return []byte("G")
+	case curr == 'Z':
+		return []byte("S")
+	case curr == 'M':
+		return []byte("N")
+	case curr == 'K' && next == 'N':
+		return []byte("NN")
+	case curr == 'K':
+		return []byte("C")
+	case curr == 'S' && next == 'C' && afterNext == 'H':
+		return []byte("SSS")
+	case curr == 'P' && next == 'H':
+		return []byte("FF")
+	case curr == 'H' && (!isVowel(prev) || !isVowel(next)):
+		return []byte{prev}
+	case curr == 'W' && isVowel(prev):
+		return []byte{prev}
+	}
+	return []byte{curr}
+}
+
+func isVowel(letter byte) bool {
+	return strings.IndexByte("AEIOU", letter) >= 0
+}
@@ -0,0 +1,103 @@
+package phonetic
+
+import (
+	"testing"
+)
+
+func TestSoundex(t *testing.T) {
+	soundexTestData := []struct {
+		name     string
+		expected string
+	}{
+		{name: "Robert", expected: "R163"},
+		{name: "Rupert", expected: "R163"},
+		{name: "Rubin", expected: "R150"},
+		{name: "Ashcraft", expected: "A261"},
+		{name: "Ashcroft", expected: "A261"},
+		{name: "Tymczak", expected: "T522"},
+		{name: "Pfister", expected: "P236"},
+		{name: "Honeyman", expected: "H555"},
+		{name: "Smith", expected: "S530"},
+		{name: "Smyth", expected: "S530"},
+		{name: "Lee", expected: "L000"},
+		{name: "o'Brien", expected: "O165"},
+		{name: " ", expected: ""},
+	}
+	for _, td := range soundexTestData {
+		if result := Soundex(td.name); result != td.expected {
+			t.Fatalf("unexpected Soundex code for %q: \n\tresult: %q\n\texpect: %q\n", td.name, result, td.expected)
+		}
+	}
+}
+
+func TestNYSIIS(t *testing.T) {
+	nysiisTestData := []struct {
+		name     string
+		expected string
+	}{
+		{name: "Katherine", expected: "CATARA"},
+		{name: "Catherine", expected: "CATARA"},
+		{name: "Steven", expected: "STAFAN"},
+		{name: "Stephen", expected: "STAFAN"},
+		{name: "Philip", expected: "FALAP"},
+		{name: "Phillip", expected: "FALAP"},
+		{name: "Sean", expected: "SAN"},
+		{name: "Shawn", expected: "SAN"},
+		{name: "Knight", expected: "NAGT"},
+		{name: "Macintosh", expected: "MCANT"},
+		{name: "Schmidt", expected: "SNAD"},
+		{name: "Bob", expected: "BAB"},
+		{name: "Robert", expected: "RABAD"},
+		{name: "", expected: ""},
+	}
+	for _, td := range nysiisTestData {
+		if result := NYSIIS(td.name); result != td.expected {
+			t.Fatalf("unexpected NYSIIS code for %q: \n\tresult: %q\n\texpect: %q\n", td.name, result, td.expected)
+		}
+	}
+}
+
+func TestDoubleMetaphone(t *testing.T) {
+	doubleMetaphoneTestData := []struct {
+		name               string
+		primary, alternate string
+	}{
+		{name: "Smith", primary: "SM0", alternate: "XMT"},
+		{name: "Smyth", primary: "SM0", alternate: "XMT"},
+		{name: "Schmidt", primary: "XMT", alternate: "SMT"},
+		{name: "Katherine", primary: "K0RN", alternate: "KTRN"},
+		{name: "Catherine", primary: "K0RN", alternate: "KTRN"},
+		{name: "Jon", primary: "JN", alternate: "AN"},
+		{name: "John", primary: "JN", alternate: "AN"},
+		{name: "Jean", primary: "JN", alternate: "AN"},
+		{name: "Steven", primary: "STFN", alternate: "STFN"},
+		{name: "Stephen", primary: "STFN", alternate: "STFN"},
+		{name: "Michael", primary: "MKL", alternate: "MXL"},
+		{name: "Thompson", primary: "TMPS", alternate: "TMPS"},
+		{name: "Jose", primary: "HS", alternate: "HS"},
+		{name: "Gallegos", primary: "KLKS", alternate: "KKS"},
+		{name: "Arnow", primary: "ARN", alternate: "ARNF"},
+		{name: "Xavier", primary: "SF", alternate: "SFR"},
+		{name: "Wasserman", primary: "ASRM", alternate: "FSRM"},
+		{name: "Çelik", primary: "SLK", alternate: "SLK"},
+		{name: "", primary: "", alternate: ""},
+	}
+	for _, td := range doubleMetaphoneTestData {
+		primary, alternate := DoubleMetaphone(td.name)
+		if primary != td.primary || alternate != td.alternate {
+			t.Fatalf("unexpected Double Metaphone codes for %q: \n\tresult: %q %q\n\texpect: %q %q\n", td.name, primary, alternate, td.primary, td.alternate)
+		}
+	}
+}
+
+// Nicknames are spelled too differently from the names they stand for to
+// sound alike.
+func TestNicknamesDoNotSoundAlike(t *testing.T) {
+	for _, names := range [][2]string{{"Bob", "Robert"}, {"Liz", "Elizabeth"}, {"Bill", "William"}} {
+		a, _ := DoubleMetaphone(names[0])
+		b, _ := DoubleMetaphone(names[1])
+		if Soundex(names[0]) == Soundex(names[1]) || NYSIIS(names[0]) == NYSIIS(names[1]) || a == b {
+			t.Fatalf("unexpected phonetic match of %q and %q\n", names[0], names[1])
+		}
+	}
+}
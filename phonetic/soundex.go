@@ -0,0 +1,61 @@
+// Package phonetic encodes names by how they sound, so that spellings of the
+// same name such as Katherine and Catherine, or Smith and Smyth, get the same
+// code. It provides Soundex, NYSIIS and Double Metaphone, which differ in how
+// much of a name they keep and which languages' spellings they allow for.
+package phonetic
+
+import (
+	"strings"
+	"unicode"
+)
+
+// soundexDigits maps the consonants to their Soundex digits. Vowels, H, W and
+// Y have none.
+var soundexDigits = map[rune]byte{
+	'B': '1', 'F': '1', 'P': '1', 'V': '1',
+	'C': '2', 'G': '2', 'J': '2', 'K': '2', 'Q': '2', 'S': '2', 'X': '2', 'Z': '2',
+	'D': '3', 'T': '3',
+	'L': '4',
+	'M': '5', 'N': '5',
+	'R': '6',
+}
+
+// Soundex returns the American Soundex code of name: its first letter followed
+// by three digits for the consonants after it, as in R163 for Robert and
+// Rupert. Adjacent consonants with the same digit, including those separated
+// only by H or W, count once. Characters other than the letters A to Z are
+// ignored, and a name without any has the empty code.
+func Soundex(name string) string {
+	letters := asciiLetters(name)
+	if len(letters) == 0 {
+		return ""
+	}
+	code := []byte{letters[0]}
+	last := soundexDigits[rune(letters[0])]
+	for _, letter := range letters[1:] {
+		digit, ok := soundexDigits[rune(letter)]
+		switch {
+		case ok && digit != last:
+			code = append(code, digit)
+			if len(code) == 4 {
+				return string(code)
+			}
+			last = digit
+		case !ok && letter != 'H' && letter != 'W':
+			// A vowel separates consonants with the same digit.
+			last = 0
+		}
+	}
+	return string(code) + strings.Repeat("0", 4-len(code))
+}
+
+// asciiLetters returns the letters A to Z in name, uppercased.
+func asciiLetters(name string) []byte {
+	var letters []byte
+	for _, r := range strings.ToUpper(name) {
+		if r <= unicode.MaxASCII && r >= 'A' && r <= 'Z' {
+			letters = append(letters, byte(r))
+		}
+	}
+	return letters
+}
@@ -2,4 +2,5 @@
 # TODO: Add better flag handling through getopts
 apikey="$1";
 port="$2";
-docker run --rm -it -p $port:$port slpeople "$apikey" "$port"
+# Pass the API key through the environment so that it is not visible in the process list.
+SLPEOPLE_API_KEY="$apikey" docker run --rm -it -p $port:$port -e SLPEOPLE_API_KEY -e SLPEOPLE_PORT="$port" slpeople
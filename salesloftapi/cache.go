@@ -0,0 +1,180 @@
+package salesloftapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCacheTTL is how long a people snapshot is served before a
+	// background refresh is started.
+	DefaultCacheTTL = time.Minute
+
+	// staleSnapshotTTLs is how many TTLs old a snapshot may grow while its
+	// refreshes fail before Check reports the cache as unready.
+	staleSnapshotTTLs = 3
+)
+
+var errCacheCold = errors.New("no snapshot of people has been fetched yet")
+
+type (
+	// PeopleCache holds the most recent People snapshot. Once the snapshot is
+	// older than the TTL it continues to be served while a single background
+	// refresh fetches a new one (stale-while-revalidate). Concurrent callers
+	// that arrive while a refresh is running share that refresh rather than
+	// starting their own.
+	//
+	// Background refreshes are not tied to any caller's context. The first
+	// fetch, which callers must wait for, is cancelled once every waiting
+	// caller has given up.
+	PeopleCache struct {
+		fetch func(context.Context) (*People, error)
+		ttl   time.Duration
+		now   func() time.Time
+
+		mu        sync.Mutex
+		people    *People
+		fetchedAt time.Time
+		refresh   *refreshCall
+		// refreshErr is the error of the latest fetch, cleared once one
+		// succeeds.
+		refreshErr error
+	}
+	refreshCall struct {
+		done    chan struct{}
+		cancel  context.CancelFunc
+		waiters int
+		people  *People
+		err     error
+	}
+)
+
+// NewPeopleCache creates a cache whose snapshots are produced by fetch.
+func NewPeopleCache(fetch func(context.Context) (*People, error), ttl time.Duration) *PeopleCache {
+	return &PeopleCache{
+		fetch: fetch,
+		ttl:   ttl,
+		now:   time.Now,
+	}
+}
+
+// Snapshot returns the current snapshot and its age, satisfying
+// PeopleSource. Until a snapshot exists
+// callers block on the fetch, returning early if ctx is done; afterwards
+// calls return immediately, starting a background refresh when the snapshot
+// has outlived the TTL.
+func (c *PeopleCache) Snapshot(ctx context.Context) (*People, time.Duration, error) {
+	c.mu.Lock()
+	if c.people != nil {
+		people, age := c.people, c.now().Sub(c.fetchedAt)
+		result := "hit"
+		if age >= c.ttl {
+			result = "stale"
+			if c.refresh == nil {
+				c.startRefreshLocked()
+			}
+		}
+		cacheLookups.WithLabelValues(result).Inc()
+		c.mu.Unlock()
+		return people, age, nil
+	}
+	cacheLookups.WithLabelValues("miss").Inc()
+	call := c.refresh
+	if call == nil {
+		call = c.startRefreshLocked()
+	}
+	call.waiters++
+	c.mu.Unlock()
+
+	select {
+	case <-call.done:
+	case <-ctx.Done():
+		c.mu.Lock()
+		call.waiters--
+		if call.waiters == 0 && c.refresh == call {
+			// Nobody is waiting for this fetch any more, so abandon it and
+			// let the next caller start afresh.
+			call.cancel()
+			c.refresh = nil
+		}
+		c.mu.Unlock()
+		return nil, 0, ctx.Err()
+	}
+	if call.err != nil {
+		return nil, 0, call.err
+	}
+	return call.people, 0, nil
+}
+
+// Age reports how old the current snapshot is and whether one exists.
+func (c *PeopleCache) Age() (time.Duration, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.people == nil {
+		return 0, false
+	}
+	return c.now().Sub(c.fetchedAt), true
+}
+
+// Check is a readiness check. It fails until the first snapshot has been
+// fetched, and once refreshes have failed for so long that the snapshot is
+// more than staleSnapshotTTLs TTLs old. It starts a fetch of a missing or
+// stale snapshot itself, as an unready service is sent no requests that
+// would.
+func (c *PeopleCache) Check(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	age := c.now().Sub(c.fetchedAt)
+	if (c.people == nil || age >= c.ttl) && c.refresh == nil {
+		c.startRefreshLocked()
+	}
+	if c.people == nil {
+		return errCacheCold
+	}
+	if c.refreshErr != nil && age > staleSnapshotTTLs*c.ttl {
+		return fmt.Errorf("the people snapshot is %v old as refreshing it failed: %v", age.Truncate(time.Second), c.refreshErr)
+	}
+	return nil
+}
+
+func (c *PeopleCache) startRefreshLocked() *refreshCall {
+	ctx, cancel := context.WithCancel(context.Background())
+	call := &refreshCall{done: make(chan struct{}), cancel: cancel}
+	c.refresh = call
+	go func() {
+		defer cancel()
+		call.people, call.err = c.fetch(ctx)
+		c.mu.Lock()
+		switch {
+		case call.err == nil:
+			c.people = call.people
+			c.fetchedAt = c.now()
+			c.refreshErr = nil
+		case ctx.Err() == nil:
+			// A fetch abandoned by its waiters is not a failure.
+			c.refreshErr = call.err
+			cacheRefreshErrors.Inc()
+			if c.people != nil {
+				log.Printf("Unable to refresh the people snapshot, still serving the one fetched %v ago: %v\n", c.now().Sub(c.fetchedAt).Truncate(time.Second), call.err)
+			}
+		}
+		if c.refresh == call {
+			c.refresh = nil
+		}
+		c.mu.Unlock()
+		close(call.done)
+	}()
+	return call
+}
+
+// SetSnapshotAge reports the age of the people snapshot a response was built
+// from using the standard Age header, in whole seconds.
+func SetSnapshotAge(w http.ResponseWriter, age time.Duration) {
+	w.Header().Set("Age", strconv.Itoa(int(age/time.Second)))
+}
@@ -0,0 +1,180 @@
+package salesloftapi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingFetcher returns a new single person snapshot on every call, using
+// the call number as the person's ID. Calls block until release is closed.
+type countingFetcher struct {
+	calls   int32
+	release chan struct{}
+	err     error
+}
+
+func (f *countingFetcher) fetch(ctx context.Context) (*People, error) {
+	n := atomic.AddInt32(&f.calls, 1)
+	if f.release != nil {
+		<-f.release
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &People{{ID: int(n)}}, nil
+}
+
+func waitForRefresh(c *PeopleCache) {
+	c.mu.Lock()
+	call := c.refresh
+	c.mu.Unlock()
+	if call != nil {
+		<-call.done
+	}
+}
+
+func TestPeopleCacheCollapsesConcurrentFetches(t *testing.T) {
+	fetcher := &countingFetcher{release: make(chan struct{})}
+	cache := NewPeopleCache(fetcher.fetch, time.Minute)
+
+	var wg sync.WaitGroup
+	results := make([]*People, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _, _ = cache.Snapshot(context.Background())
+		}(i)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(fetcher.release)
+	wg.Wait()
+
+	if calls := atomic.LoadInt32(&fetcher.calls); calls != 1 {
+		t.Fatalf("expected a single upstream fetch, got %d\n", calls)
+	}
+	for i, people := range results {
+		if people == nil || (*people)[0].ID != 1 {
+			t.Fatalf("caller %d did not receive the shared snapshot: %#v\n", i, people)
+		}
+	}
+}
+
+func TestPeopleCacheServesStaleWhileRefreshing(t *testing.T) {
+	now := time.Now()
+	fetcher := &countingFetcher{}
+	cache := NewPeopleCache(fetcher.fetch, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	people, age, err := cache.Snapshot(context.Background())
+	if err != nil || (*people)[0].ID != 1 || age != 0 {
+		t.Fatalf("unexpected first snapshot: \n\tpeople: %#v\n\tage: %v\n\terr: %v\n", people, age, err)
+	}
+
+	now = now.Add(30 * time.Second)
+	people, age, _ = cache.Snapshot(context.Background())
+	waitForRefresh(cache)
+	if (*people)[0].ID != 1 || age != 30*time.Second || atomic.LoadInt32(&fetcher.calls) != 1 {
+		t.Fatalf("expected the fresh snapshot to be served without a refresh: \n\tpeople: %#v\n\tage: %v\n", people, age)
+	}
+
+	now = now.Add(time.Minute)
+	people, age, _ = cache.Snapshot(context.Background())
+	if (*people)[0].ID != 1 || age != 90*time.Second {
+		t.Fatalf("expected the stale snapshot to be served: \n\tpeople: %#v\n\tage: %v\n", people, age)
+	}
+	waitForRefresh(cache)
+	people, age, _ = cache.Snapshot(context.Background())
+	if (*people)[0].ID != 2 || age != 0 {
+		t.Fatalf("expected the refreshed snapshot: \n\tpeople: %#v\n\tage: %v\n", people, age)
+	}
+}
+
+func TestPeopleCacheKeepsSnapshotWhenRefreshFails(t *testing.T) {
+	now := time.Now()
+	fetcher := &countingFetcher{}
+	cache := NewPeopleCache(fetcher.fetch, time.Minute)
+	cache.now = func() time.Time { return now }
+	cache.Snapshot(context.Background())
+
+	fetcher.err = errors.New("upstream unavailable")
+	now = now.Add(2 * time.Minute)
+	cache.Snapshot(context.Background())
+	waitForRefresh(cache)
+
+	people, age, err := cache.Snapshot(context.Background())
+	if err != nil || (*people)[0].ID != 1 || age != 2*time.Minute {
+		t.Fatalf("expected the previous snapshot to survive a failed refresh: \n\tpeople: %#v\n\tage: %v\n\terr: %v\n", people, age, err)
+	}
+}
+
+func TestPeopleCacheCheck(t *testing.T) {
+	now := time.Now()
+	fetcher := &countingFetcher{}
+	cache := NewPeopleCache(fetcher.fetch, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	// The check itself fetches the first snapshot.
+	if err := cache.Check(context.Background()); err != errCacheCold {
+		t.Fatalf("expected a cold cache to be unready, got %v\n", err)
+	}
+	waitForRefresh(cache)
+	if err := cache.Check(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+
+	// A snapshot a few TTLs old is fine while its refreshes succeed, or
+	// have only just started failing.
+	fetcher.err = errors.New("upstream unavailable")
+	now = now.Add(2 * time.Minute)
+	if err := cache.Check(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	waitForRefresh(cache)
+	now = now.Add(2 * time.Minute)
+	fetcher.err = nil
+	if err := cache.Check(context.Background()); err == nil {
+		t.Fatalf("expected a snapshot 4 TTLs old that failed to refresh to be unready\n")
+	}
+
+	waitForRefresh(cache)
+	if err := cache.Check(context.Background()); err != nil {
+		t.Fatalf("expected a successful refresh to make the cache ready again, got %v\n", err)
+	}
+}
+
+func TestPeopleCacheReturnsInitialFetchError(t *testing.T) {
+	fetcher := &countingFetcher{err: errors.New("upstream unavailable")}
+	cache := NewPeopleCache(fetcher.fetch, time.Minute)
+	if _, _, err := cache.Snapshot(context.Background()); err == nil {
+		t.Fatalf("expected the initial fetch error to be returned\n")
+	}
+	if _, ok := cache.Age(); ok {
+		t.Fatalf("expected no snapshot after a failed initial fetch\n")
+	}
+}
+
+func TestPeopleCacheAbandonsFetchWithoutWaiters(t *testing.T) {
+	fetchCtx := make(chan context.Context, 2)
+	cache := NewPeopleCache(func(ctx context.Context) (*People, error) {
+		fetchCtx <- ctx
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, _, err := cache.Snapshot(ctx); err != context.Canceled {
+		t.Fatalf("expected the caller's cancellation to be returned, got %v\n", err)
+	}
+	select {
+	case ctx := <-fetchCtx:
+		<-ctx.Done()
+	case <-time.After(time.Second):
+		t.Fatalf("expected the fetch to be cancelled once nobody was waiting\n")
+	}
+}
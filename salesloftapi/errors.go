@@ -8,7 +8,7 @@ import (
 func ErrListPeople(err error) render.Renderer {
 	return &errors.ErrResponse{
 		Err:            err,
-		HTTPStatusCode: 500,
+		HTTPStatusCode: HTTPStatusCode(err),
 		StatusText:     "Error listing people from SalesLoft API.",
 		ErrorText:      err.Error(),
 	}
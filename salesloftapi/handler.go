@@ -13,15 +13,24 @@ type (
 		Metadata SalesLoftApiMetadata `json:"metadata"`
 		Data     *People              `json:"data"`
 	}
+	Handler struct {
+		source PeopleSource
+	}
 )
 
+// NewHandler creates a Handler that lists the people provided by source.
+func NewHandler(source PeopleSource) *Handler {
+	return &Handler{source: source}
+}
+
 /*** Level 1: List People ***/
-func ListPeopleHandler(w http.ResponseWriter, r *http.Request) {
-	people, err := ListPeople()
+func (h *Handler) ListPeopleHandler(w http.ResponseWriter, r *http.Request) {
+	people, age, err := h.source.Snapshot(r.Context())
 	if err != nil {
 		render.Render(w, r, ErrListPeople(err))
 		return
 	}
+	SetSnapshotAge(w, age)
 	if err := render.Render(w, r, NewPeopleListResponse(people)); err != nil {
 		render.Render(w, r, errors.ErrRender(err))
 		return
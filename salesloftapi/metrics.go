@@ -0,0 +1,30 @@
+package salesloftapi
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	upstreamRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "salesloft_requests_total",
+		Help: "Requests made to the SalesLoft API, by status code or \"error\" when no response arrived.",
+	}, []string{"code"})
+	upstreamRequestDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "salesloft_request_duration_seconds",
+		Help: "Latency of requests to the SalesLoft API, including reading the response.",
+	})
+	pagesPerListing = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "salesloft_list_people_pages",
+		Help:    "Pages of people fetched by each successful ListPeople.",
+		Buckets: []float64{1, 2, 5, 10, 20, 50, 100, 200, 500, 1000},
+	})
+	cacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "people_cache_requests_total",
+		Help: "People cache lookups, by result: hit, stale (served while refreshing) or miss.",
+	}, []string{"result"})
+	cacheRefreshErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "people_cache_refresh_errors_total",
+		Help: "Failed fetches of a people snapshot, whether the first or a background refresh.",
+	})
+)
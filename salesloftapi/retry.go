@@ -0,0 +1,195 @@
+package salesloftapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// SalesLoft reports the remaining request budget for the current minute
+	// on every response.
+	rateLimitRemainingHeader = "X-RateLimit-Remaining-Minute"
+
+	// StatusClientClosedRequest is the non-standard status, from nginx, for a
+	// request whose client went away before it was answered.
+	StatusClientClosedRequest = 499
+)
+
+var (
+	// ErrInvalidAPIKey is returned when the SalesLoft API rejects the API key.
+	ErrInvalidAPIKey = errors.New("the SalesLoft API rejected the API key; check that it is correct and has not been revoked")
+
+	// DefaultRetryPolicy retries transient failures a handful of times,
+	// backing off from half a second up to half a minute.
+	DefaultRetryPolicy = RetryPolicy{
+		MaxAttempts: 5,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    30 * time.Second,
+	}
+)
+
+type (
+	// RetryPolicy controls how the client retries transient failures. Each
+	// retry waits a jittered, exponentially increasing delay starting at
+	// BaseDelay and capped at MaxDelay, unless the SalesLoft API has said how
+	// long to wait.
+	RetryPolicy struct {
+		MaxAttempts int
+		BaseDelay   time.Duration
+		MaxDelay    time.Duration
+	}
+	// StatusError is returned when the SalesLoft API responds with a status
+	// code other than 200 OK.
+	StatusError struct {
+		StatusCode int
+		// RetryAfter is how long the SalesLoft API asked the client to wait
+		// before trying again, or zero if it did not say.
+		RetryAfter time.Duration
+	}
+	// RetriesExhaustedError is returned when every attempt permitted by the
+	// retry policy failed with a transient error.
+	RetriesExhaustedError struct {
+		Attempts int
+		Err      error
+	}
+)
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("SalesLoft API responded with %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Temporary reports whether the request may succeed if it is retried.
+func (e *StatusError) Temporary() bool {
+	switch e.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (e *RetriesExhaustedError) Error() string {
+	return fmt.Sprintf("gave up after %d attempts: %v", e.Attempts, e.Err)
+}
+
+// newStatusError builds a StatusError from a response, working out how long
+// to wait from the Retry-After header or, failing that, from an exhausted
+// rate limit budget.
+func newStatusError(resp *http.Response, maxDelay time.Duration) *StatusError {
+	e := &StatusError{StatusCode: resp.StatusCode}
+	if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+		e.RetryAfter = retryAfter
+	} else if resp.StatusCode == http.StatusTooManyRequests && resp.Header.Get(rateLimitRemainingHeader) == "0" {
+		e.RetryAfter = maxDelay
+	}
+	return e
+}
+
+// parseRetryAfter accepts either form of the Retry-After header: a number of
+// seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// delay returns how long to wait before the given retry (starting at 1). The
+// exponential delay is jittered between half and all of its value so that
+// concurrent page fetches do not retry in lockstep. A wait the SalesLoft API
+// asked for is honoured up to MaxDelay.
+func (p RetryPolicy) delay(retry int, retryAfter time.Duration) time.Duration {
+	if retryAfter > 0 {
+		if retryAfter > p.MaxDelay {
+			return p.MaxDelay
+		}
+		return retryAfter
+	}
+	d := p.BaseDelay
+	for i := 1; i < retry && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(d-half)+1))
+}
+
+// sleepContext waits for d, returning early with ctx's error if ctx is done
+// first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// isTransient reports whether a failed request is worth retrying: a response
+// whose status says so, a network error that reports a timeout or a temporary
+// condition, or a connection that was reset or refused. Failures that would
+// only recur, such as a malformed URL or an untrusted certificate, are not
+// retried, and nothing is once ctx is done.
+func isTransient(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Temporary()
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var netErr interface {
+		Timeout() bool
+		Temporary() bool
+	}
+	return errors.As(err, &netErr) && (netErr.Timeout() || netErr.Temporary())
+}
+
+// HTTPStatusCode maps an error from the client to the status code a handler
+// should respond with. A cancelled request gets StatusClientClosedRequest, as
+// only a client that went away cancels one.
+func HTTPStatusCode(err error) int {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest
+	}
+	var exhaustedErr *RetriesExhaustedError
+	var statusErr *StatusError
+	switch {
+	case errors.As(err, &exhaustedErr):
+		return http.StatusServiceUnavailable
+	case errors.As(err, &statusErr):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
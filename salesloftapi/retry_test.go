@@ -0,0 +1,261 @@
+package salesloftapi
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// scriptedServer responds to each request with the next scripted status code
+// and headers, and with a single page of people once the script runs out.
+type scriptedServer struct {
+	mu       sync.Mutex
+	statuses []int
+	headers  []map[string]string
+	requests int
+}
+
+func (s *scriptedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	i := s.requests
+	s.requests++
+	s.mu.Unlock()
+	if i < len(s.statuses) {
+		if i < len(s.headers) {
+			for k, v := range s.headers[i] {
+				w.Header().Set(k, v)
+			}
+		}
+		w.WriteHeader(s.statuses[i])
+		fmt.Fprint(w, `{"error": "scripted failure"}`)
+		return
+	}
+	fmt.Fprint(w, `{"metadata": {"paging": {"per_page": 100, "current_page": 1}}, "data": [{"id": 1}]}`)
+}
+
+func newRetryTestClient(url string, attempts int) (*SalesLoftClient, *[]time.Duration) {
+	client := NewClient("key", url, 1)
+	client.SetRetryPolicy(RetryPolicy{MaxAttempts: attempts, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second})
+	var sleeps []time.Duration
+	client.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return client, &sleeps
+}
+
+func TestGetPeopleRetriesTransientFailures(t *testing.T) {
+	server := &scriptedServer{statuses: []int{500, 503, 502}}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	client, sleeps := newRetryTestClient(ts.URL, 5)
+
+	people, err := client.ListPeople()
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	if len(*people) != 1 || server.requests != 4 || len(*sleeps) != 3 {
+		t.Fatalf("expected 3 retries before success: \n\trequests: %d\n\tsleeps: %v\n", server.requests, *sleeps)
+	}
+	for i, d := range *sleeps {
+		max := 100 * time.Millisecond << uint(i)
+		if d < max/2 || d > max {
+			t.Fatalf("retry %d slept %v, expected between %v and %v\n", i+1, d, max/2, max)
+		}
+	}
+}
+
+func TestGetPeopleHonoursRetryAfter(t *testing.T) {
+	server := &scriptedServer{
+		statuses: []int{429, 429, 429},
+		headers: []map[string]string{
+			{"Retry-After": "7"},
+			{"Retry-After": "60"},
+			{rateLimitRemainingHeader: "0"},
+		},
+	}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	client, sleeps := newRetryTestClient(ts.URL, 5)
+	client.SetRetryPolicy(RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: 10 * time.Second})
+
+	if _, err := client.ListPeople(); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	// Longer waits than the policy's MaxDelay are cut short.
+	if expected := []time.Duration{7 * time.Second, 10 * time.Second, 10 * time.Second}; !cmp.Equal(*sleeps, expected) {
+		t.Fatalf("the client did not wait as the SalesLoft API asked: \n\tresult: %v\n\texpect: %v\n", *sleeps, expected)
+	}
+}
+
+func TestGetPeopleExhaustsRetryBudget(t *testing.T) {
+	server := &scriptedServer{statuses: []int{503, 503, 503, 503}}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	client, _ := newRetryTestClient(ts.URL, 3)
+
+	_, err := client.ListPeople()
+	exhausted, ok := err.(*RetriesExhaustedError)
+	if !ok {
+		t.Fatalf("expected a *RetriesExhaustedError, got %#v\n", err)
+	}
+	if statusErr, ok := exhausted.Err.(*StatusError); exhausted.Attempts != 3 || !ok || statusErr.StatusCode != 503 {
+		t.Fatalf("unexpected exhausted error: %#v\n", exhausted)
+	}
+	if server.requests != 3 {
+		t.Fatalf("expected 3 requests, got %d\n", server.requests)
+	}
+	if code := HTTPStatusCode(err); code != http.StatusServiceUnavailable {
+		t.Fatalf("expected the handlers to respond with 503, got %d\n", code)
+	}
+}
+
+func TestGetPeopleDoesNotRetryClientErrors(t *testing.T) {
+	server := &scriptedServer{statuses: []int{401}}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	client, sleeps := newRetryTestClient(ts.URL, 5)
+
+	_, err := client.ListPeople()
+	if statusErr, ok := err.(*StatusError); !ok || statusErr.StatusCode != 401 {
+		t.Fatalf("expected a 401 *StatusError, got %#v\n", err)
+	}
+	if server.requests != 1 || len(*sleeps) != 0 {
+		t.Fatalf("expected no retries: \n\trequests: %d\n\tsleeps: %v\n", server.requests, *sleeps)
+	}
+}
+
+func TestGetPeopleDoesNotRetryPermanentTransportErrors(t *testing.T) {
+	client, sleeps := newRetryTestClient("ftp://salesloft.invalid/v2/people.json", 5)
+
+	if _, err := client.ListPeople(); err == nil {
+		t.Fatalf("expected an unsupported scheme to fail\n")
+	}
+	if len(*sleeps) != 0 {
+		t.Fatalf("expected no retries, got sleeps %v\n", *sleeps)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	transientTestData := []struct {
+		err      error
+		expected bool
+	}{
+		{err: &StatusError{StatusCode: 503}, expected: true},
+		{err: &StatusError{StatusCode: 404}, expected: false},
+		{err: &url.Error{Op: "Get", URL: "http://x", Err: refused}, expected: true},
+		{err: &url.Error{Op: "Get", URL: "http://x", Err: syscall.ECONNRESET}, expected: true},
+		{err: &url.Error{Op: "Get", URL: "http://x", Err: &net.DNSError{Err: "timeout", IsTimeout: true}}, expected: true},
+		{err: &url.Error{Op: "Get", URL: "ftp://x", Err: errors.New("unsupported protocol scheme")}, expected: false},
+		{err: &url.Error{Op: "Get", URL: "https://x", Err: x509.UnknownAuthorityError{}}, expected: false},
+		{err: &url.Error{Op: "Get", URL: "http://x", Err: context.Canceled}, expected: false},
+	}
+	for _, td := range transientTestData {
+		if result := isTransient(context.Background(), td.err); result != td.expected {
+			t.Fatalf("unexpected transience of %v: \n\tresult: %v\n\texpect: %v\n", td.err, result, td.expected)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if isTransient(ctx, &url.Error{Op: "Get", URL: "http://x", Err: refused}) {
+		t.Fatalf("expected nothing to be retried once the context is done\n")
+	}
+}
+
+func TestHTTPStatusCode(t *testing.T) {
+	statusTestData := []struct {
+		err      error
+		expected int
+	}{
+		{err: context.DeadlineExceeded, expected: http.StatusGatewayTimeout},
+		{err: &url.Error{Op: "Get", URL: "http://x", Err: context.DeadlineExceeded}, expected: http.StatusGatewayTimeout},
+		{err: fmt.Errorf("listing people: %w", context.Canceled), expected: StatusClientClosedRequest},
+		{err: &RetriesExhaustedError{Attempts: 5, Err: &StatusError{StatusCode: 503}}, expected: http.StatusServiceUnavailable},
+		{err: &StatusError{StatusCode: 404}, expected: http.StatusBadGateway},
+		{err: errors.New("unexpected end of JSON input"), expected: http.StatusInternalServerError},
+	}
+	for _, td := range statusTestData {
+		if result := HTTPStatusCode(td.err); result != td.expected {
+			t.Fatalf("unexpected status code for %v: \n\tresult: %d\n\texpect: %d\n", td.err, result, td.expected)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2019, 1, 7, 12, 0, 0, 0, time.UTC)
+	retryAfterTestData := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{value: "", expected: 0, ok: false},
+		{value: "0", expected: 0, ok: true},
+		{value: "120", expected: 2 * time.Minute, ok: true},
+		{value: "Mon, 07 Jan 2019 12:00:30 GMT", expected: 30 * time.Second, ok: true},
+		{value: "Mon, 07 Jan 2019 11:59:00 GMT", expected: 0, ok: true},
+		{value: "soon", expected: 0, ok: false},
+	}
+	for _, td := range retryAfterTestData {
+		if result, ok := parseRetryAfter(td.value, now); result != td.expected || ok != td.ok {
+			t.Fatalf("unexpected Retry-After for %q: \n\tresult: %v, %v\n\texpect: %v, %v\n", td.value, result, ok, td.expected, td.ok)
+		}
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 30 * time.Second}
+	delayTestData := []struct {
+		retry      int
+		retryAfter time.Duration
+		min, max   time.Duration
+	}{
+		{retry: 1, min: 500 * time.Millisecond, max: time.Second},
+		{retry: 3, min: 2 * time.Second, max: 4 * time.Second},
+		{retry: 10, min: 15 * time.Second, max: 30 * time.Second},
+		{retry: 1, retryAfter: 10 * time.Second, min: 10 * time.Second, max: 10 * time.Second},
+		// A server asking for a day is only waited on for MaxDelay.
+		{retry: 1, retryAfter: 24 * time.Hour, min: 30 * time.Second, max: 30 * time.Second},
+	}
+	for _, td := range delayTestData {
+		if result := policy.delay(td.retry, td.retryAfter); result < td.min || result > td.max {
+			t.Fatalf("unexpected delay for retry %d after %v: \n\tresult: %v\n\texpect: between %v and %v\n", td.retry, td.retryAfter, result, td.min, td.max)
+		}
+	}
+}
+
+func TestCheckAPIKey(t *testing.T) {
+	checkTestData := []struct {
+		statuses []int
+		expected func(error) bool
+	}{
+		{statuses: nil, expected: func(err error) bool { return err == nil }},
+		{statuses: []int{401}, expected: func(err error) bool { return err == ErrInvalidAPIKey }},
+		{statuses: []int{403}, expected: func(err error) bool { return err == ErrInvalidAPIKey }},
+		{statuses: []int{503}, expected: func(err error) bool {
+			statusErr, ok := err.(*StatusError)
+			return ok && statusErr.StatusCode == 503
+		}},
+	}
+	for _, td := range checkTestData {
+		ts := httptest.NewServer(&scriptedServer{statuses: td.statuses})
+		err := NewClient("key", ts.URL, 1).CheckAPIKey(context.Background())
+		ts.Close()
+		if !td.expected(err) {
+			t.Fatalf("statuses %v: unexpected error: %v\n", td.statuses, err)
+		}
+	}
+}
@@ -1,16 +1,32 @@
 package salesloftapi
 
 import (
+	"context"
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultPerPage is the largest page size the SalesLoft API permits.
+	DefaultPerPage = 100
+	// DefaultConcurrency is the number of pages fetched in parallel when
+	// no concurrency limit is provided.
+	DefaultConcurrency = 4
 )
 
 type (
 	SalesLoftClient struct {
-		apiKey string
-		apiUrl string
+		apiKey      string
+		apiUrl      string
+		concurrency int
+		perPage     int
+		retryPolicy RetryPolicy
+		httpClient  *http.Client
+		sleep       func(context.Context, time.Duration) error
 	}
 	SimplifiedPersonView struct {
 		ID                    int    `json:"id"`
@@ -32,7 +48,7 @@ type (
 		PerPage     *int `json:"per_page"`
 		CurrentPage *int `json:"current_page"`
 		NextPage    *int `json:"next_page"`
-		PrevPage    *int `json:prev_page"`
+		PrevPage    *int `json:"prev_page"`
 		TotalPages  *int `json:"total_pages,omitempty"`
 		TotalCount  *int `json:"total_count,omitempty"`
 	}
@@ -41,56 +57,246 @@ type (
 	}
 )
 
-var (
-	slClient *SalesLoftClient
-)
+// NewClient creates a SalesLoft client. The concurrency value bounds the
+// number of pages fetched in parallel by ListPeople; values less than 1 fall
+// back to DefaultConcurrency.
+func NewClient(apiKey, apiUrl string, concurrency int) *SalesLoftClient {
+	if concurrency < 1 {
+		concurrency = DefaultConcurrency
+	}
+	return &SalesLoftClient{
+		apiKey:      apiKey,
+		apiUrl:      apiUrl,
+		concurrency: concurrency,
+		perPage:     DefaultPerPage,
+		retryPolicy: DefaultRetryPolicy,
+		httpClient:  &http.Client{},
+		sleep:       sleepContext,
+	}
+}
+
+// SetRetryPolicy replaces the client's DefaultRetryPolicy. A policy with
+// fewer than one attempt makes a single attempt.
+func (slClient *SalesLoftClient) SetRetryPolicy(policy RetryPolicy) {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	slClient.retryPolicy = policy
+}
+
+// SetPageSize sets how many people are requested per page, at most
+// DefaultPerPage.
+func (slClient *SalesLoftClient) SetPageSize(perPage int) {
+	if perPage < 1 || perPage > DefaultPerPage {
+		perPage = DefaultPerPage
+	}
+	slClient.perPage = perPage
+}
 
-func InitializeClient(apiKey, apiUrl string) *SalesLoftClient {
-	slClient = &SalesLoftClient{
-		apiKey: apiKey,
-		apiUrl: apiUrl,
+// SetTimeout limits how long each request to the SalesLoft API may take,
+// including reading the response. Zero means no limit.
+func (slClient *SalesLoftClient) SetTimeout(timeout time.Duration) {
+	slClient.httpClient.Timeout = timeout
+}
+
+// CheckAPIKey asks the SalesLoft API for a single person to confirm that it
+// is reachable and accepts the client's API key. A rejected key is reported
+// as ErrInvalidAPIKey.
+func (slClient *SalesLoftClient) CheckAPIKey(ctx context.Context) error {
+	_, err := slClient.getPage(ctx, 1, 1)
+	if statusErr, ok := err.(*StatusError); ok {
+		switch statusErr.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden:
+			return ErrInvalidAPIKey
+		}
 	}
-	return slClient
+	return err
 }
 
-func ListPeople() (*People, error) {
+// ListPeople lists every person available to the client's API key.
+func (slClient *SalesLoftClient) ListPeople() (*People, error) {
+	return slClient.ListPeopleContext(context.Background())
+}
+
+// Snapshot lists every person, satisfying PeopleSource. The people are always
+// fetched fresh, so the age is zero.
+func (slClient *SalesLoftClient) Snapshot(ctx context.Context) (*People, time.Duration, error) {
+	people, err := slClient.ListPeopleContext(ctx)
+	return people, 0, err
+}
+
+// ListPeopleContext fetches the first page of people to learn the total
+// number of pages and then fetches the remaining pages concurrently, bounded
+// by the client's concurrency limit. The people are returned in page order.
+// If any page fails, or ctx is done, in-flight requests are abandoned, no
+// further pages are requested and the first error is returned.
+func (slClient *SalesLoftClient) ListPeopleContext(ctx context.Context) (*People, error) {
+	first, err := slClient.getPeople(ctx, slClient.perPage, 1)
+	if err != nil {
+		return nil, err
+	}
 	people := People{}
-	pp := 100
-	np := 0
-	var perPage *int
-	var nextPage *int
-	perPage = &pp
-	nextPage = &np
-	resp := &SalesLoftApiPeopleResponse{}
-	var err error
-	for err == nil && nextPage != nil {
-		resp, err = slClient.getPeople(*perPage, *nextPage)
-		if err != nil || resp.Data == nil {
+	if first.Data == nil {
+		pagesPerListing.Observe(1)
+		return &people, nil
+	}
+	people = append(people, []SimplifiedPersonView(*first.Data)...)
+
+	paging := first.Metadata.Paging
+	if paging.NextPage == nil {
+		pagesPerListing.Observe(1)
+		return &people, nil
+	}
+	perPage := slClient.perPage
+	if paging.PerPage != nil {
+		perPage = *paging.PerPage
+	}
+	// Without a page count the remaining pages can only be discovered one at a time.
+	if paging.TotalPages == nil {
+		return slClient.listPeopleSequentially(ctx, people, perPage, *paging.NextPage)
+	}
+
+	totalPages := *paging.TotalPages
+	if totalPages < 2 {
+		pagesPerListing.Observe(1)
+		return &people, nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	pages := make([]*People, totalPages+1)
+	pageNumbers := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	workers := slClient.concurrency
+	if workers > totalPages-1 {
+		workers = totalPages - 1
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for page := range pageNumbers {
+				resp, err := slClient.getPeople(ctx, perPage, page)
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+				} else {
+					pages[page] = resp.Data
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+dispatch:
+	for page := 2; page <= totalPages; page++ {
+		select {
+		case pageNumbers <- page:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(pageNumbers)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for _, data := range pages[2:] {
+		if data != nil {
+			people = append(people, []SimplifiedPersonView(*data)...)
+		}
+	}
+	pagesPerListing.Observe(float64(totalPages))
+	return &people, nil
+}
+
+func (slClient *SalesLoftClient) listPeopleSequentially(ctx context.Context, people People, perPage, page int) (*People, error) {
+	fetched := page - 1
+	nextPage := &page
+	for nextPage != nil {
+		resp, err := slClient.getPeople(ctx, perPage, *nextPage)
+		if err != nil {
+			return nil, err
+		}
+		fetched++
+		if resp.Data == nil {
 			break
 		}
 		people = append(people, []SimplifiedPersonView(*resp.Data)...)
-		perPage = resp.Metadata.Paging.PerPage
 		nextPage = resp.Metadata.Paging.NextPage
 	}
-	if err != nil {
-		return nil, err
-	}
+	pagesPerListing.Observe(float64(fetched))
 	return &people, nil
 }
 
-func (slClient *SalesLoftClient) getPeople(perPage, page int) (*SalesLoftApiPeopleResponse, error) {
-	client := &http.Client{}
+// getPeople fetches a single page of people, retrying transient failures
+// according to the client's retry policy. Once ctx is done its error is
+// returned rather than being retried.
+func (slClient *SalesLoftClient) getPeople(ctx context.Context, perPage, page int) (*SalesLoftApiPeopleResponse, error) {
+	policy := slClient.retryPolicy
+	var err error
+	for attempt := 1; attempt <= policy.MaxAttempts; attempt++ {
+		var resp *SalesLoftApiPeopleResponse
+		resp, err = slClient.getPage(ctx, perPage, page)
+		if err == nil {
+			return resp, nil
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if !isTransient(ctx, err) {
+			return nil, err
+		}
+		if attempt == policy.MaxAttempts {
+			break
+		}
+		var retryAfter time.Duration
+		if statusErr, ok := err.(*StatusError); ok {
+			retryAfter = statusErr.RetryAfter
+		}
+		if err := slClient.sleep(ctx, policy.delay(attempt, retryAfter)); err != nil {
+			return nil, err
+		}
+	}
+	return nil, &RetriesExhaustedError{Attempts: policy.MaxAttempts, Err: err}
+}
+
+func (slClient *SalesLoftClient) getPage(ctx context.Context, perPage, page int) (*SalesLoftApiPeopleResponse, error) {
 	req, err := http.NewRequest("GET", slClient.apiUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
 	req.Header.Add("Authorization", "Bearer "+slClient.apiKey)
 	q := req.URL.Query()
 	q.Add("per_page", strconv.Itoa(perPage))
 	q.Add("page", strconv.Itoa(page))
+	q.Add("include_paging_counts", "true")
 	req.URL.RawQuery = q.Encode()
-	resp, err := client.Do(req)
+	start := time.Now()
+	resp, err := slClient.httpClient.Do(req)
 	if err != nil {
+		upstreamRequests.WithLabelValues("error").Inc()
+		upstreamRequestDuration.Observe(time.Since(start).Seconds())
 		return nil, err
 	}
+	defer resp.Body.Close()
+	upstreamRequests.WithLabelValues(strconv.Itoa(resp.StatusCode)).Inc()
+	if resp.StatusCode != http.StatusOK {
+		upstreamRequestDuration.Observe(time.Since(start).Seconds())
+		return nil, newStatusError(resp, slClient.retryPolicy.MaxDelay)
+	}
 	body, err := ioutil.ReadAll(resp.Body)
+	upstreamRequestDuration.Observe(time.Since(start).Seconds())
 	if err != nil {
 		return nil, err
 	}
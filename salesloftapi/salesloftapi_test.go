@@ -0,0 +1,209 @@
+package salesloftapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakePeopleServer serves numbered people in pages the way the SalesLoft API
+// does. Requests for a page listed in failPages receive a malformed body.
+type fakePeopleServer struct {
+	totalCount int
+	perPage    int
+	omitTotals bool
+	failPages  map[int]bool
+	delay      time.Duration
+
+	mu          sync.Mutex
+	inFlight    int
+	maxInFlight int
+	requested   []int
+}
+
+func (f *fakePeopleServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.inFlight++
+	if f.inFlight > f.maxInFlight {
+		f.maxInFlight = f.inFlight
+	}
+	f.mu.Unlock()
+	defer func() {
+		f.mu.Lock()
+		f.inFlight--
+		f.mu.Unlock()
+	}()
+	time.Sleep(f.delay)
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	f.mu.Lock()
+	f.requested = append(f.requested, page)
+	f.mu.Unlock()
+	if f.failPages[page] {
+		fmt.Fprint(w, "{not json")
+		return
+	}
+
+	totalPages := (f.totalCount + f.perPage - 1) / f.perPage
+	data := People{}
+	for id := (page-1)*f.perPage + 1; id <= page*f.perPage && id <= f.totalCount; id++ {
+		data = append(data, SimplifiedPersonView{ID: id})
+	}
+	paging := SalesLoftApiPagingMetadata{PerPage: intPtr(f.perPage), CurrentPage: intPtr(page)}
+	if page < totalPages {
+		paging.NextPage = intPtr(page + 1)
+	}
+	if !f.omitTotals {
+		paging.TotalPages = intPtr(totalPages)
+		paging.TotalCount = intPtr(f.totalCount)
+	}
+	json.NewEncoder(w).Encode(SalesLoftApiPeopleResponse{
+		Metadata: SalesLoftApiMetadata{Paging: paging},
+		Data:     &data,
+	})
+}
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func peopleIDs(people *People) []int {
+	ids := make([]int, len(*people))
+	for i := range *people {
+		ids[i] = (*people)[i].ID
+	}
+	return ids
+}
+
+func sequentialIDs(n int) []int {
+	ids := make([]int, n)
+	for i := range ids {
+		ids[i] = i + 1
+	}
+	return ids
+}
+
+func TestListPeople(t *testing.T) {
+	listPeopleTestData := []struct {
+		name        string
+		totalCount  int
+		omitTotals  bool
+		concurrency int
+	}{
+		{name: "single page", totalCount: 7, concurrency: 4},
+		{name: "many pages", totalCount: 95, concurrency: 4},
+		{name: "serial pool", totalCount: 95, concurrency: 1},
+		{name: "more workers than pages", totalCount: 25, concurrency: 16},
+		{name: "no paging counts", totalCount: 42, omitTotals: true, concurrency: 4},
+		{name: "no people", totalCount: 0, concurrency: 4},
+	}
+	for _, td := range listPeopleTestData {
+		fake := &fakePeopleServer{totalCount: td.totalCount, perPage: 10, omitTotals: td.omitTotals}
+		server := httptest.NewServer(fake)
+		client := NewClient("key", server.URL, td.concurrency)
+		people, err := client.ListPeople()
+		server.Close()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v\n", td.name, err)
+		}
+		if result, expected := peopleIDs(people), sequentialIDs(td.totalCount); !cmp.Equal(result, expected) {
+			t.Fatalf("%s: the people were not returned in page order: \n\tresult: %v\n\texpect: %v\n", td.name, result, expected)
+		}
+	}
+}
+
+func TestListPeopleBoundsConcurrency(t *testing.T) {
+	fake := &fakePeopleServer{totalCount: 200, perPage: 10, delay: 10 * time.Millisecond}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := NewClient("key", server.URL, 3)
+	if _, err := client.ListPeople(); err != nil {
+		t.Fatalf("unexpected error: %v\n", err)
+	}
+	if fake.maxInFlight > 3 {
+		t.Fatalf("expected at most 3 requests in flight, observed %d\n", fake.maxInFlight)
+	}
+	if fake.maxInFlight < 2 {
+		t.Fatalf("expected pages to be fetched concurrently, observed %d request(s) in flight\n", fake.maxInFlight)
+	}
+}
+
+func TestListPeopleFailsOnPageError(t *testing.T) {
+	fake := &fakePeopleServer{totalCount: 500, perPage: 10, failPages: map[int]bool{3: true}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := NewClient("key", server.URL, 2)
+	people, err := client.ListPeople()
+	if err == nil {
+		t.Fatalf("expected an error when a page fails, got %d people\n", len(*people))
+	}
+	if people != nil {
+		t.Fatalf("expected no people when a page fails, got %d\n", len(*people))
+	}
+	if len(fake.requested) >= 50 {
+		t.Fatalf("expected fetching to stop after the failed page, but all %d pages were requested\n", len(fake.requested))
+	}
+}
+
+func TestListPeopleContextStopsOnCancel(t *testing.T) {
+	fake := &fakePeopleServer{totalCount: 1000, perPage: 10, delay: 20 * time.Millisecond}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := NewClient("key", server.URL, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	people, err := client.ListPeopleContext(ctx)
+	if err != context.Canceled || people != nil {
+		t.Fatalf("expected the listing to be cancelled: \n\tpeople: %v\n\terr: %v\n", people, err)
+	}
+	fake.mu.Lock()
+	requested := len(fake.requested)
+	fake.mu.Unlock()
+	if requested >= 100 {
+		t.Fatalf("expected pagination to stop on cancellation, but all %d pages were requested\n", requested)
+	}
+}
+
+func TestListPeopleContextHonoursDeadline(t *testing.T) {
+	fake := &fakePeopleServer{totalCount: 1000, perPage: 10, omitTotals: true, delay: 20 * time.Millisecond}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	client := NewClient("key", server.URL, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.ListPeopleContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected the deadline to be exceeded, got %v\n", err)
+	}
+	if code := HTTPStatusCode(context.DeadlineExceeded); code != http.StatusGatewayTimeout {
+		t.Fatalf("expected the handlers to respond with 504, got %d\n", code)
+	}
+}
+
+func TestGetPeopleDoesNotRetryAfterCancel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	client := NewClient("key", server.URL, 1)
+	client.SetRetryPolicy(RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.ListPeopleContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("expected the deadline to interrupt the backoff, got %v\n", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expected the backoff to be interrupted, but it took %v\n", elapsed)
+	}
+}
@@ -0,0 +1,270 @@
+// Package slmock is a stand-in for the SalesLoft people API. It serves
+// /v2/people.json from a fixture with the same paging metadata as the real
+// API, so the service can be developed and tested without network access or
+// an API key.
+package slmock
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	slapi "github.com/slpeople/salesloftapi"
+)
+
+const (
+	// PeoplePath is the path of the people endpoint, relative to the API root.
+	PeoplePath = "/v2/people.json"
+
+	// The real API pages 25 people at a time unless asked for up to 100.
+	defaultPerPage = 25
+	maxPerPage     = 100
+)
+
+const (
+	// NoFault serves every request normally.
+	NoFault FaultKind = iota
+	// FaultRateLimit responds 429 Too Many Requests.
+	FaultRateLimit
+	// FaultServerError responds 500 Internal Server Error.
+	FaultServerError
+	// FaultMalformed responds 200 OK with a truncated JSON body.
+	FaultMalformed
+)
+
+type (
+	// FaultKind selects the failure injected into a response.
+	FaultKind int
+	// Options configures a Server. The zero value serves every request
+	// immediately without checking credentials.
+	Options struct {
+		// Token is the API key expected as a bearer token. When empty any
+		// request is accepted.
+		Token string
+		// Latency is added before every response.
+		Latency time.Duration
+		// Fault is injected into the first FaultFirst requests and then into
+		// each following request with probability FaultRate.
+		Fault      FaultKind
+		FaultFirst int
+		FaultRate  float64
+		// RetryAfter, when positive, is sent with rate limited responses.
+		RetryAfter time.Duration
+		// Seed seeds the random fault injection.
+		Seed int64
+	}
+	// Server serves a fixed list of people as the SalesLoft API would.
+	Server struct {
+		people  slapi.People
+		options Options
+		mux     *http.ServeMux
+
+		mu       sync.Mutex
+		rand     *rand.Rand
+		requests int
+	}
+	errorResponse struct {
+		Error string `json:"error"`
+	}
+)
+
+// ParseFaultKind parses the names accepted on the command line: "none",
+// "429", "500" and "malformed".
+func ParseFaultKind(name string) (FaultKind, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return NoFault, nil
+	case "429", "ratelimit":
+		return FaultRateLimit, nil
+	case "500", "servererror":
+		return FaultServerError, nil
+	case "malformed":
+		return FaultMalformed, nil
+	}
+	return NoFault, fmt.Errorf("unknown fault %q: expected none, 429, 500 or malformed", name)
+}
+
+// NewServer creates a Server for the given people.
+func NewServer(people slapi.People, options Options) *Server {
+	s := &Server{
+		people:  people,
+		options: options,
+		mux:     http.NewServeMux(),
+		rand:    rand.New(rand.NewSource(options.Seed)),
+	}
+	s.mux.HandleFunc(PeoplePath, s.listPeople)
+	return s
+}
+
+// Requests reports how many requests the server has received.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) listPeople(w http.ResponseWriter, r *http.Request) {
+	fault := s.nextFault()
+	time.Sleep(s.options.Latency)
+
+	if r.Method != "GET" {
+		writeJSON(w, http.StatusMethodNotAllowed, errorResponse{"method not allowed"})
+		return
+	}
+	if s.options.Token != "" && r.Header.Get("Authorization") != "Bearer "+s.options.Token {
+		writeJSON(w, http.StatusUnauthorized, errorResponse{"invalid or missing API key"})
+		return
+	}
+	switch fault {
+	case FaultRateLimit:
+		if s.options.RetryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(s.options.RetryAfter/time.Second)))
+		}
+		w.Header().Set("X-RateLimit-Remaining-Minute", "0")
+		writeJSON(w, http.StatusTooManyRequests, errorResponse{"rate limit exceeded"})
+		return
+	case FaultServerError:
+		writeJSON(w, http.StatusInternalServerError, errorResponse{"internal server error"})
+		return
+	case FaultMalformed:
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"metadata": {"paging": {"per_page": `)
+		return
+	}
+
+	q := r.URL.Query()
+	perPage, err := queryInt(q.Get("per_page"), defaultPerPage)
+	if err != nil || perPage < 1 {
+		writeJSON(w, http.StatusUnprocessableEntity, errorResponse{"per_page must be a positive integer"})
+		return
+	}
+	if perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+	page, err := queryInt(q.Get("page"), 1)
+	if err != nil || page < 1 {
+		writeJSON(w, http.StatusUnprocessableEntity, errorResponse{"page must be a positive integer"})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.page(perPage, page, q.Get("include_paging_counts") == "true"))
+}
+
+// page builds the response for a page the way the SalesLoft API does: pages
+// past the end are empty, and the totals are only included when asked for.
+func (s *Server) page(perPage, page int, includeCounts bool) *slapi.SalesLoftApiPeopleResponse {
+	totalCount := len(s.people)
+	totalPages := (totalCount + perPage - 1) / perPage
+
+	data := slapi.People{}
+	if start := (page - 1) * perPage; start < totalCount {
+		end := start + perPage
+		if end > totalCount {
+			end = totalCount
+		}
+		data = append(data, s.people[start:end]...)
+	}
+	paging := slapi.SalesLoftApiPagingMetadata{
+		PerPage:     intPtr(perPage),
+		CurrentPage: intPtr(page),
+	}
+	if page < totalPages {
+		paging.NextPage = intPtr(page + 1)
+	}
+	if page > 1 {
+		paging.PrevPage = intPtr(page - 1)
+	}
+	if includeCounts {
+		paging.TotalPages = intPtr(totalPages)
+		paging.TotalCount = intPtr(totalCount)
+	}
+	return &slapi.SalesLoftApiPeopleResponse{
+		Metadata: slapi.SalesLoftApiMetadata{Paging: paging},
+		Data:     &data,
+	}
+}
+
+func (s *Server) nextFault() FaultKind {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if s.options.Fault == NoFault {
+		return NoFault
+	}
+	if s.requests <= s.options.FaultFirst || s.rand.Float64() < s.options.FaultRate {
+		return s.options.Fault
+	}
+	return NoFault
+}
+
+// LoadFixture reads people from a file. Files ending in .jsonl hold one
+// person per line; any other file holds either a JSON array of people or a
+// SalesLoft API response with the people under "data".
+func LoadFixture(path string) (slapi.People, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Ext(path) == ".jsonl" {
+		return parseJSONLines(contents)
+	}
+	contents = bytes.TrimSpace(contents)
+	people := slapi.People{}
+	if bytes.HasPrefix(contents, []byte("[")) {
+		if err := json.Unmarshal(contents, &people); err != nil {
+			return nil, err
+		}
+		return people, nil
+	}
+	resp := slapi.SalesLoftApiPeopleResponse{Data: &people}
+	if err := json.Unmarshal(contents, &resp); err != nil {
+		return nil, err
+	}
+	return people, nil
+}
+
+func parseJSONLines(contents []byte) (slapi.People, error) {
+	people := slapi.People{}
+	scanner := bufio.NewScanner(bytes.NewReader(contents))
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var person slapi.SimplifiedPersonView
+		if err := json.Unmarshal(text, &person); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		people = append(people, person)
+	}
+	return people, scanner.Err()
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func queryInt(value string, fallback int) (int, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func intPtr(i int) *int {
+	return &i
+}
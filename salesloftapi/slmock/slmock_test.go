@@ -0,0 +1,142 @@
+package slmock
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	slapi "github.com/slpeople/salesloftapi"
+)
+
+func TestLoadFixture(t *testing.T) {
+	people, err := LoadFixture("testdata/people.json")
+	if err != nil || len(people) != 62 {
+		t.Fatalf("unable to load the JSON fixture: %d people, %v\n", len(people), err)
+	}
+	lines, err := LoadFixture("testdata/people.jsonl")
+	if err != nil {
+		t.Fatalf("unable to load the JSONL fixture: %v\n", err)
+	}
+	if !cmp.Equal(lines, people[:3]) {
+		t.Fatalf("the JSONL fixture does not match the JSON fixture: \n\tresult: %#v\n\texpect: %#v\n", lines, people[:3])
+	}
+}
+
+func TestServerPaging(t *testing.T) {
+	server := NewServer(make(slapi.People, 55), Options{})
+	pagingTestData := []struct {
+		query    string
+		count    int
+		expected slapi.SalesLoftApiPagingMetadata
+	}{
+		{
+			query:    "",
+			count:    25,
+			expected: slapi.SalesLoftApiPagingMetadata{PerPage: intPtr(25), CurrentPage: intPtr(1), NextPage: intPtr(2)},
+		},
+		{
+			query: "?per_page=20&page=2&include_paging_counts=true",
+			count: 20,
+			expected: slapi.SalesLoftApiPagingMetadata{
+				PerPage: intPtr(20), CurrentPage: intPtr(2), NextPage: intPtr(3), PrevPage: intPtr(1),
+				TotalPages: intPtr(3), TotalCount: intPtr(55),
+			},
+		},
+		{
+			query:    "?per_page=500&page=1",
+			count:    55,
+			expected: slapi.SalesLoftApiPagingMetadata{PerPage: intPtr(100), CurrentPage: intPtr(1)},
+		},
+		{
+			query:    "?per_page=50&page=4",
+			count:    0,
+			expected: slapi.SalesLoftApiPagingMetadata{PerPage: intPtr(50), CurrentPage: intPtr(4), PrevPage: intPtr(3)},
+		},
+	}
+	for _, td := range pagingTestData {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("GET", PeoplePath+td.query, nil))
+		resp := &slapi.SalesLoftApiPeopleResponse{}
+		if err := json.NewDecoder(w.Body).Decode(resp); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("%q: unexpected response: %d, %v\n", td.query, w.Code, err)
+		}
+		if len(*resp.Data) != td.count || !cmp.Equal(resp.Metadata.Paging, td.expected) {
+			expected, _ := json.Marshal(td.expected)
+			result, _ := json.Marshal(resp.Metadata.Paging)
+			t.Fatalf("%q: unexpected page: \n\tcount: %d\n\tresult: %s\n\texpect: %s\n", td.query, len(*resp.Data), result, expected)
+		}
+	}
+}
+
+func TestServerChecksToken(t *testing.T) {
+	server := NewServer(slapi.People{}, Options{Token: "secret"})
+	for header, expected := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"Bearer secret": http.StatusOK,
+	} {
+		r := httptest.NewRequest("GET", PeoplePath, nil)
+		r.Header.Set("Authorization", header)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, r)
+		if w.Code != expected {
+			t.Fatalf("Authorization %q: expected %d, got %d\n", header, expected, w.Code)
+		}
+	}
+}
+
+func TestServerInjectsFaults(t *testing.T) {
+	people, _ := LoadFixture("testdata/people.json")
+	faultTestData := []struct {
+		fault   FaultKind
+		checkFn func(error) bool
+	}{
+		{FaultRateLimit, func(err error) bool {
+			e, ok := err.(*slapi.RetriesExhaustedError)
+			return ok && e.Err.(*slapi.StatusError).StatusCode == http.StatusTooManyRequests
+		}},
+		{FaultServerError, func(err error) bool {
+			e, ok := err.(*slapi.RetriesExhaustedError)
+			return ok && e.Err.(*slapi.StatusError).StatusCode == http.StatusInternalServerError
+		}},
+		{FaultMalformed, func(err error) bool {
+			_, exhausted := err.(*slapi.RetriesExhaustedError)
+			return err != nil && !exhausted
+		}},
+	}
+	for _, td := range faultTestData {
+		ts := httptest.NewServer(NewServer(people, Options{Fault: td.fault, FaultRate: 1}))
+		client := slapi.NewClient("key", ts.URL+PeoplePath, 2)
+		client.SetRetryPolicy(slapi.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+		_, err := client.ListPeopleContext(context.Background())
+		ts.Close()
+		if !td.checkFn(err) {
+			t.Fatalf("fault %d: unexpected error: %#v\n", td.fault, err)
+		}
+	}
+
+	// Faults limited to the first requests are recovered from by retrying.
+	ts := httptest.NewServer(NewServer(people, Options{Fault: FaultServerError, FaultFirst: 2}))
+	defer ts.Close()
+	client := slapi.NewClient("key", ts.URL+PeoplePath, 2)
+	client.SetRetryPolicy(slapi.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond})
+	result, err := client.ListPeopleContext(context.Background())
+	if err != nil || !cmp.Equal(*result, people) {
+		t.Fatalf("expected the client to recover from the first faults: %v\n", err)
+	}
+}
+
+func TestParseFaultKind(t *testing.T) {
+	for name, expected := range map[string]FaultKind{"none": NoFault, "429": FaultRateLimit, "500": FaultServerError, "malformed": FaultMalformed} {
+		if result, err := ParseFaultKind(name); err != nil || result != expected {
+			t.Fatalf("%q: expected %d, got %d, %v\n", name, expected, result, err)
+		}
+	}
+	if _, err := ParseFaultKind("teapot"); err == nil {
+		t.Fatalf("expected an unknown fault to be rejected\n")
+	}
+}
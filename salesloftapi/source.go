@@ -0,0 +1,21 @@
+package salesloftapi
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	// PeopleSource supplies the people the handlers work with, along with
+	// how old that data is. The SalesLoftClient and PeopleCache are both
+	// PeopleSources, and tests can provide their own with PeopleSourceFunc.
+	PeopleSource interface {
+		Snapshot(ctx context.Context) (*People, time.Duration, error)
+	}
+	// PeopleSourceFunc adapts an ordinary function to a PeopleSource.
+	PeopleSourceFunc func(ctx context.Context) (*People, time.Duration, error)
+)
+
+func (f PeopleSourceFunc) Snapshot(ctx context.Context) (*People, time.Duration, error) {
+	return f(ctx)
+}
@@ -1,6 +1,4 @@
 #!/bin/bash
-# TODO: Add better flag handling through getopts
-apikey="$1";
-port="$2";
-echo "apikey=$apikey, port=$port";
-./slpeople.app --apikey "$apikey" --port "$port"
+# The API key is read from the SLPEOPLE_API_KEY environment variable (or
+# SLPEOPLE_API_KEY_FILE) so that it is not visible in the process list.
+./slpeople.app "$@"